@@ -3,55 +3,240 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"tinytoe/internal/app"
 	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
 )
 
+// exitInterrupted is the conventional status for a run stopped by a signal.
+const exitInterrupted = 130
+
+// errInterrupted is the cancellation cause recorded when a signal arrives.
+var errInterrupted = errors.New("interrupted by signal")
+
 func main() {
-	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+	ctx, stop := notifyContext(context.Background(), os.Stderr)
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitCode(ctx, err))
+	}
+}
+
+// notifyContext returns a context cancelled by the first SIGINT or SIGTERM, so
+// an in-flight migration is cancelled on the server and rolled back before the
+// command reports and exits. A second signal exits immediately.
+func notifyContext(parent context.Context, stderr io.Writer) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(stderr, "Received %s; cancelling and rolling back (signal again to exit immediately)\n", sig)
+			cancel(errInterrupted)
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			fmt.Fprintln(stderr, "Received second signal; exiting without waiting for rollback")
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			cancel(nil)
+		})
+	}
+}
+
+// exitCode maps errors carrying an explicit code (e.g. from `tinytoe status`)
+// to that code, falling back to 1 for all other failures. Runs stopped by a
+// signal exit with exitInterrupted, and runs that never reached the database
+// with app.ExitUnreachable, which takes precedence over a command's own
+// failure code (lint's and check-schema's 2) so scripts can detect an
+// unreachable database the same way for every command.
+func exitCode(ctx context.Context, err error) int {
+	if errors.Is(context.Cause(ctx), errInterrupted) {
+		return exitInterrupted
+	}
+	var unreachable *migrate.UnreachableError
+	if errors.As(err, &unreachable) {
+		return app.ExitUnreachable
+	}
+	var coder interface{ ExitCode() int }
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return 1
+}
+
+// globalOptions holds flags accepted by every command.
+type globalOptions struct {
+	output *string
+	env    *string
+	// wait is set by --wait; waitTimeout by --wait=<duration>.
+	wait        bool
+	waitTimeout *time.Duration
+}
+
+// loadOptions seeds config.LoadOptions with the global overrides.
+func (g globalOptions) loadOptions() config.LoadOptions {
+	return config.LoadOptions{
+		OutputOverride:         g.output,
+		EnvOverride:            g.env,
+		Wait:                   g.wait,
+		ConnectTimeoutOverride: g.waitTimeout,
+	}
+}
+
+// outputFormat resolves the effective output format for error reporting,
+// before or without a successfully loaded configuration.
+func (g globalOptions) outputFormat() string {
+	raw := os.Getenv("TINYTOE_OUTPUT")
+	if g.output != nil {
+		raw = *g.output
+	}
+	format, err := config.ParseOutput(raw)
+	if err != nil {
+		return ui.FormatText
+	}
+	return format
+}
+
+// extractGlobalFlags removes global flags from anywhere in args so they can be
+// given before or after the command name.
+func extractGlobalFlags(args []string) (globalOptions, []string, error) {
+	var globals globalOptions
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch name {
+		case "--output":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return globals, nil, fmt.Errorf("--output requires a format (text or json)")
+				}
+				i++
+				value = args[i]
+			}
+			format, err := config.ParseOutput(value)
+			if err != nil {
+				return globals, nil, err
+			}
+			globals.output = &format
+		case "--env":
+			if !hasValue {
+				if i+1 >= len(args) {
+					return globals, nil, fmt.Errorf("--env requires an environment name from %s", config.DefaultConfigFile)
+				}
+				i++
+				value = args[i]
+			}
+			if strings.TrimSpace(value) == "" {
+				return globals, nil, fmt.Errorf("--env requires an environment name from %s", config.DefaultConfigFile)
+			}
+			env := value
+			globals.env = &env
+		case "--wait":
+			globals.wait = true
+			if hasValue {
+				timeout, err := time.ParseDuration(strings.TrimSpace(value))
+				if err != nil || timeout < 0 {
+					return globals, nil, fmt.Errorf("--wait expects a duration such as 30s or 2m, got %q", value)
+				}
+				globals.waitTimeout = &timeout
+			}
+		default:
+			rest = append(rest, args[i])
+		}
 	}
+	return globals, rest, nil
 }
 
-func run(args []string, stdout, stderr io.Writer) error {
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
 	if _, skip := os.LookupEnv("TINYTOE_SKIP_DOTENV"); !skip {
 		if err := loadDotenv(".env"); err != nil {
 			return fmt.Errorf("load .env: %w", err)
 		}
 	}
 
+	globals, args, err := extractGlobalFlags(args)
+	if err != nil {
+		printUsage(stderr)
+		return err
+	}
+
 	if len(args) == 0 || isHelp(args[0]) {
 		printUsage(stdout)
 		return nil
 	}
 
+	err = runCommand(ctx, args, globals, stdout, stderr)
+	if err != nil && globals.outputFormat() == ui.FormatJSON {
+		ui.WriteError(stdout, args[0], err, exitCode(ctx, err))
+	}
+	return err
+}
+
+func runCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
 	switch args[0] {
 	case "init":
-		cfg, err := config.Load()
+		cfg, err := config.LoadWithOptions(globals.loadOptions())
 		if err != nil {
 			return err
 		}
-		return app.RunInit(context.Background(), cfg, stdout)
+		return app.RunInit(ctx, cfg, stdout)
 	case "up":
-		cfg, err := config.Load()
+		return runUpCommand(ctx, args[1:], globals, stdout, stderr)
+	case "status":
+		cfg, err := config.LoadWithOptions(globals.loadOptions())
 		if err != nil {
-			return err
+			return &app.ExitError{Code: app.StatusExitDrift, Err: err}
 		}
-		return app.RunUp(context.Background(), cfg, stdout)
+		return app.RunStatus(ctx, cfg, stdout)
 	case "dropall":
-		return runDropAllCommand(args[1:], stdout, stderr)
+		return runDropAllCommand(ctx, args[1:], globals, stdout, stderr)
 	case "reset":
-		return runResetCommand(args[1:], stdout, stderr)
+		return runResetCommand(ctx, args[1:], globals, stdout, stderr)
 	case "new":
-		return runNewCommand(args[1:], stdout, stderr)
+		return runNewCommand(ctx, args[1:], globals, stdout, stderr)
+	case "baseline":
+		return runBaselineCommand(ctx, args[1:], globals, stdout, stderr)
+	case "lint":
+		return runLintCommand(ctx, args[1:], globals, stdout, stderr)
+	case "verify":
+		return runVerifyCommand(ctx, args[1:], globals, stdout, stderr)
+	case "dump":
+		return runDumpCommand(ctx, args[1:], globals, stdout, stderr)
+	case "squash":
+		return runSquashCommand(ctx, args[1:], globals, stdout, stderr)
+	case "rebase":
+		return runRebaseCommand(ctx, args[1:], globals, stdout, stderr)
+	case "check-schema":
+		return runCheckSchemaCommand(ctx, args[1:], globals, stdout, stderr)
 	case "help":
 		printUsage(stdout)
 		return nil
@@ -70,11 +255,26 @@ func printUsage(w io.Writer) {
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, "Usage:")
 	fmt.Fprintln(w, "  tinytoe init     Initialize migrations directory and database state")
-	fmt.Fprintln(w, "  tinytoe up       Apply pending migrations to the database")
-	fmt.Fprintln(w, "  tinytoe dropall  Drop the target schema without reapplying migrations (tinytoe dropall [--force])")
-	fmt.Fprintln(w, "  tinytoe reset    Drop the target schema and reapply all migrations (tinytoe reset [--force])")
+	fmt.Fprintln(w, "  tinytoe up       Apply pending migrations to the database (tinytoe up [--dry-run] [--to <version> | --step <n>] [--dump] [--allow-out-of-order])")
+	fmt.Fprintln(w, "  tinytoe status   Show applied and pending migrations (exit 0 current, 1 pending, 2 drift, 3 unreachable)")
+	fmt.Fprintln(w, "  tinytoe dropall  Drop the target schema without reapplying migrations (tinytoe dropall [--force] [--allow-protected <database>])")
+	fmt.Fprintln(w, "  tinytoe reset    Drop the target schema and reapply all migrations (tinytoe reset [--force] [--allow-protected <database>])")
 	fmt.Fprintln(w, "  tinytoe new      Generate a new migration (tinytoe new [--force] <description>)")
+	fmt.Fprintln(w, "  tinytoe baseline Record migrations through a version as applied without running them (tinytoe baseline [--force] [--allow-existing] <version>)")
+	fmt.Fprintln(w, "  tinytoe lint     Check pending migrations for dangerous DDL (tinytoe lint [--all]; exit 1 on problems)")
+	fmt.Fprintln(w, "  tinytoe verify   Replay every migration in a temporary schema, then drop it")
+	fmt.Fprintln(w, "  tinytoe dump     Write a sorted DDL snapshot of the target schema to migrations/schema.sql")
+	fmt.Fprintln(w, "  tinytoe squash   Replace migrations through a version with one snapshot migration (tinytoe squash --through <version>)")
+	fmt.Fprintln(w, "  tinytoe rebase   Give unapplied migrations fresh timestamps after the latest applied one (tinytoe rebase [--after <version>] [--dry-run])")
+	fmt.Fprintln(w, "  tinytoe check-schema")
+	fmt.Fprintln(w, "                   Report schema changes made outside tinytoe since the last up (exit 1 on changes, 2 on failure)")
 	fmt.Fprintln(w, "  tinytoe help     Show this message")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global options:")
+	fmt.Fprintln(w, "  --output <text|json>  Output format; json emits one JSON event per line (or set TINYTOE_OUTPUT)")
+	fmt.Fprintln(w, "  --env <name>          Use the named environment from tinytoe.toml (or set TINYTOE_ENV)")
+	fmt.Fprintln(w, "  --wait[=<duration>]   Retry connecting while the database is unreachable (default 1m, or set TINYTOE_CONNECT_TIMEOUT);")
+	fmt.Fprintln(w, "                        exits with code 3 if it never becomes reachable")
 }
 
 func isHelp(arg string) bool {
@@ -141,7 +341,7 @@ func loadDotenv(path string) error {
 	return scanner.Err()
 }
 
-func runNewCommand(args []string, stdout, stderr io.Writer) error {
+func runNewCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
 	for len(args) > 0 && isHelp(args[0]) {
 		printNewUsage(stdout)
 		return nil
@@ -176,9 +376,8 @@ func runNewCommand(args []string, stdout, stderr io.Writer) error {
 	description := strings.Join(descriptionParts, " ")
 
 	requireDatabase := false
-	opts := config.LoadOptions{
-		RequireDatabase: &requireDatabase,
-	}
+	opts := globals.loadOptions()
+	opts.RequireDatabase = &requireDatabase
 	if forceSpecified {
 		opts.ForceOverride = &forceFlag
 	}
@@ -200,7 +399,90 @@ func printNewUsage(w io.Writer) {
 	fmt.Fprintln(w, "Creates a new migration file using a UTC timestamp prefix and the provided description.")
 }
 
-func runDropAllCommand(args []string, stdout, stderr io.Writer) error {
+func runUpCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	for len(args) > 0 && isHelp(args[0]) {
+		printUpUsage(stdout)
+		return nil
+	}
+
+	var opts migrate.UpOptions
+	dump := false
+	outOfOrder := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case arg == "--dry-run":
+			opts.DryRun = true
+		case arg == "--dump":
+			dump = true
+		case arg == "--allow-out-of-order":
+			outOfOrder = true
+		case name == "--to":
+			if !hasValue {
+				if i+1 >= len(args) {
+					printUpUsage(stderr)
+					return fmt.Errorf("--to requires a version")
+				}
+				i++
+				value = args[i]
+			}
+			opts.Target = strings.TrimSpace(value)
+			if opts.Target == "" {
+				printUpUsage(stderr)
+				return fmt.Errorf("--to requires a version")
+			}
+		case name == "--step":
+			if !hasValue {
+				if i+1 >= len(args) {
+					printUpUsage(stderr)
+					return fmt.Errorf("--step requires a count")
+				}
+				i++
+				value = args[i]
+			}
+			steps, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || steps <= 0 {
+				printUpUsage(stderr)
+				return fmt.Errorf("--step requires a positive count, got %q", value)
+			}
+			opts.Steps = steps
+		case strings.HasPrefix(arg, "--"):
+			printUpUsage(stderr)
+			return fmt.Errorf("unknown flag %s", arg)
+		default:
+			printUpUsage(stderr)
+			return fmt.Errorf("unexpected argument %s", arg)
+		}
+	}
+
+	cfg, err := config.LoadWithOptions(globals.loadOptions())
+	if err != nil {
+		return err
+	}
+	if dump {
+		cfg.DumpOnUp = true
+	}
+	if outOfOrder {
+		cfg.AllowOutOfOrder = true
+	}
+
+	return app.RunUpWithOptions(ctx, cfg, opts, stdout)
+}
+
+func printUpUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe up [--dry-run] [--to <version> | --step <n>] [--dump] [--allow-out-of-order]")
+	fmt.Fprintln(w, "Applies pending migrations in timestamp order.")
+	fmt.Fprintln(w, "Use --dry-run to print the migrations and SQL that would be applied without changing the database.")
+	fmt.Fprintln(w, "Use --to to stop after the migration with the given version, or --step to apply only the next n pending migrations.")
+	fmt.Fprintln(w, "Use --dump to refresh the schema dump afterwards, as TINYTOE_DUMP_ON_UP or dump_on_up = true do on every run.")
+	fmt.Fprintln(w, "Use --allow-out-of-order to apply pending migrations older than the latest applied one, e.g. from a merged branch, as TINYTOE_ALLOW_OUT_OF_ORDER or allow_out_of_order = true do on every run.")
+}
+
+func runDropAllCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
 	for len(args) > 0 && isHelp(args[0]) {
 		printDropAllUsage(stdout)
 		return nil
@@ -208,11 +490,28 @@ func runDropAllCommand(args []string, stdout, stderr io.Writer) error {
 
 	forceFlag := false
 	forceSpecified := false
-	for _, arg := range args {
+	allowProtected := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
 		switch {
 		case arg == "--force":
 			forceFlag = true
 			forceSpecified = true
+		case name == "--allow-protected":
+			if !hasValue {
+				if i+1 >= len(args) {
+					printDropAllUsage(stderr)
+					return fmt.Errorf("--allow-protected requires a database name")
+				}
+				i++
+				value = args[i]
+			}
+			allowProtected = strings.TrimSpace(value)
+			if allowProtected == "" {
+				printDropAllUsage(stderr)
+				return fmt.Errorf("--allow-protected requires a database name")
+			}
 		case strings.HasPrefix(arg, "--"):
 			printDropAllUsage(stderr)
 			return fmt.Errorf("unknown flag %s", arg)
@@ -222,7 +521,7 @@ func runDropAllCommand(args []string, stdout, stderr io.Writer) error {
 		}
 	}
 
-	opts := config.LoadOptions{}
+	opts := globals.loadOptions()
 	if forceSpecified {
 		opts.ForceOverride = &forceFlag
 	}
@@ -232,18 +531,21 @@ func runDropAllCommand(args []string, stdout, stderr io.Writer) error {
 		return err
 	}
 
-	return app.RunDropAll(context.Background(), cfg, os.Stdin, stdout)
+	cfg.AllowProtected = allowProtected
+
+	return app.RunDropAll(ctx, cfg, os.Stdin, stdout)
 }
 
 func printDropAllUsage(w io.Writer) {
 	if w == nil {
 		w = io.Discard
 	}
-	fmt.Fprintln(w, "Usage: tinytoe dropall [--force]")
+	fmt.Fprintln(w, "Usage: tinytoe dropall [--force] [--allow-protected <database>]")
 	fmt.Fprintln(w, "Drops the target schema without recreating it. Use --force to skip the confirmation prompt.")
+	fmt.Fprintln(w, "Protected environments are refused even with --force unless --allow-protected names the database.")
 }
 
-func runResetCommand(args []string, stdout, stderr io.Writer) error {
+func runResetCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
 	for len(args) > 0 && isHelp(args[0]) {
 		printResetUsage(stdout)
 		return nil
@@ -251,11 +553,28 @@ func runResetCommand(args []string, stdout, stderr io.Writer) error {
 
 	forceFlag := false
 	forceSpecified := false
-	for _, arg := range args {
+	allowProtected := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
 		switch {
 		case arg == "--force":
 			forceFlag = true
 			forceSpecified = true
+		case name == "--allow-protected":
+			if !hasValue {
+				if i+1 >= len(args) {
+					printResetUsage(stderr)
+					return fmt.Errorf("--allow-protected requires a database name")
+				}
+				i++
+				value = args[i]
+			}
+			allowProtected = strings.TrimSpace(value)
+			if allowProtected == "" {
+				printResetUsage(stderr)
+				return fmt.Errorf("--allow-protected requires a database name")
+			}
 		case strings.HasPrefix(arg, "--"):
 			printResetUsage(stderr)
 			return fmt.Errorf("unknown flag %s", arg)
@@ -265,7 +584,7 @@ func runResetCommand(args []string, stdout, stderr io.Writer) error {
 		}
 	}
 
-	opts := config.LoadOptions{}
+	opts := globals.loadOptions()
 	if forceSpecified {
 		opts.ForceOverride = &forceFlag
 	}
@@ -275,14 +594,314 @@ func runResetCommand(args []string, stdout, stderr io.Writer) error {
 		return err
 	}
 
-	return app.RunReset(context.Background(), cfg, os.Stdin, stdout)
+	cfg.AllowProtected = allowProtected
+
+	return app.RunReset(ctx, cfg, os.Stdin, stdout)
 }
 
 func printResetUsage(w io.Writer) {
 	if w == nil {
 		w = io.Discard
 	}
-	fmt.Fprintln(w, "Usage: tinytoe reset [--force]")
+	fmt.Fprintln(w, "Usage: tinytoe reset [--force] [--allow-protected <database>]")
 	fmt.Fprintln(w, "Drops the target schema, recreates it, and reapplies all migrations from disk.")
 	fmt.Fprintln(w, "Use --force to skip the confirmation prompt (or set TINYTOE_FORCE=1).")
+	fmt.Fprintln(w, "Protected environments are refused even with --force unless --allow-protected names the database.")
+}
+
+func runBaselineCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	for len(args) > 0 && isHelp(args[0]) {
+		printBaselineUsage(stdout)
+		return nil
+	}
+
+	forceFlag := false
+	forceSpecified := false
+	allowExisting := false
+	var versions []string
+	for _, arg := range args {
+		switch {
+		case arg == "--force":
+			forceFlag = true
+			forceSpecified = true
+		case arg == "--allow-existing":
+			allowExisting = true
+		case strings.HasPrefix(arg, "--"):
+			printBaselineUsage(stderr)
+			return fmt.Errorf("unknown flag %s", arg)
+		default:
+			versions = append(versions, arg)
+		}
+	}
+
+	if len(versions) != 1 {
+		printBaselineUsage(stderr)
+		return fmt.Errorf("exactly one baseline version is required")
+	}
+
+	opts := globals.loadOptions()
+	if forceSpecified {
+		opts.ForceOverride = &forceFlag
+	}
+
+	cfg, err := config.LoadWithOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	cfg.AllowExisting = allowExisting
+
+	return app.RunBaseline(ctx, cfg, versions[0], os.Stdin, stdout)
+}
+
+func printBaselineUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe baseline [--force] [--allow-existing] <version>")
+	fmt.Fprintln(w, "Records every migration up to and including <version> as applied without executing it.")
+	fmt.Fprintln(w, "Use --force to skip the confirmation prompt (or set TINYTOE_FORCE=1).")
+	fmt.Fprintln(w, "Refuses to run when migrations are already recorded unless --allow-existing is given; already recorded versions are then skipped.")
+}
+
+func runLintCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	if len(args) > 0 && isHelp(args[0]) {
+		printLintUsage(stdout)
+		return nil
+	}
+
+	// Every failure to lint, including bad usage, exits with LintExitFailed so
+	// CI can tell it apart from problems found in the migrations.
+	all := false
+	for _, arg := range args {
+		switch {
+		case arg == "--all":
+			all = true
+		case strings.HasPrefix(arg, "--"):
+			printLintUsage(stderr)
+			return &app.ExitError{Code: app.LintExitFailed, Err: fmt.Errorf("unknown flag %s", arg)}
+		default:
+			printLintUsage(stderr)
+			return &app.ExitError{Code: app.LintExitFailed, Err: fmt.Errorf("unexpected argument %s", arg)}
+		}
+	}
+
+	// Linting every file needs no database; pending files are found there.
+	requireDatabase := !all
+	opts := globals.loadOptions()
+	opts.RequireDatabase = &requireDatabase
+
+	cfg, err := config.LoadWithOptions(opts)
+	if err != nil {
+		return &app.ExitError{Code: app.LintExitFailed, Err: err}
+	}
+
+	return app.RunLint(ctx, cfg, all, stdout)
+}
+
+func printLintUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe lint [--all]")
+	fmt.Fprintln(w, "Checks pending migrations for DDL that is dangerous on a live database. Use --all to check every file without connecting.")
+	fmt.Fprintf(w, "Rules: %s. Disable rules with TINYTOE_LINT_DISABLE or lint_disable,\n", strings.Join(migrate.LintRules, ", "))
+	fmt.Fprintln(w, "or suppress one statement with a -- tinytoe:lint-ignore=<rule> comment (lint-ignore-file=<rule> for a whole file).")
+	fmt.Fprintln(w, "Exits with 0 when clean, 1 when problems are found, 2 when linting could not run,")
+	fmt.Fprintln(w, "and 3 when the database is unreachable, like every other command.")
+}
+
+func runVerifyCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	if len(args) > 0 && isHelp(args[0]) {
+		printVerifyUsage(stdout)
+		return nil
+	}
+	if len(args) > 0 {
+		printVerifyUsage(stderr)
+		return fmt.Errorf("unexpected argument %s", args[0])
+	}
+
+	cfg, err := config.LoadWithOptions(globals.loadOptions())
+	if err != nil {
+		return err
+	}
+
+	return app.RunVerify(ctx, cfg, stdout)
+}
+
+func printVerifyUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe verify")
+	fmt.Fprintln(w, "Creates a uniquely named temporary schema, initializes it and applies every migration from scratch,")
+	fmt.Fprintln(w, "reports the failing file and statement if any, and always drops the schema afterwards.")
+}
+
+func runDumpCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	if len(args) > 0 && isHelp(args[0]) {
+		printDumpUsage(stdout)
+		return nil
+	}
+	if len(args) > 0 {
+		printDumpUsage(stderr)
+		return fmt.Errorf("unexpected argument %s", args[0])
+	}
+
+	cfg, err := config.LoadWithOptions(globals.loadOptions())
+	if err != nil {
+		return err
+	}
+
+	return app.RunDump(ctx, cfg, stdout)
+}
+
+func printDumpUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe dump")
+	fmt.Fprintln(w, "Writes the types, sequences, functions, tables, constraints, indexes, views and triggers of the target schema, sorted by name,")
+	fmt.Fprintln(w, "to schema.sql in the migrations directory (or TINYTOE_DUMP_FILE / dump_file). The file is only rewritten when it changes.")
+}
+
+func runSquashCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	for len(args) > 0 && isHelp(args[0]) {
+		printSquashUsage(stdout)
+		return nil
+	}
+
+	through := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case name == "--through":
+			if !hasValue {
+				if i+1 >= len(args) {
+					printSquashUsage(stderr)
+					return fmt.Errorf("--through requires a version")
+				}
+				i++
+				value = args[i]
+			}
+			through = strings.TrimSpace(value)
+		case strings.HasPrefix(arg, "--"):
+			printSquashUsage(stderr)
+			return fmt.Errorf("unknown flag %s", arg)
+		default:
+			printSquashUsage(stderr)
+			return fmt.Errorf("unexpected argument %s", arg)
+		}
+	}
+	if through == "" {
+		printSquashUsage(stderr)
+		return fmt.Errorf("--through requires a version")
+	}
+
+	cfg, err := config.LoadWithOptions(globals.loadOptions())
+	if err != nil {
+		return err
+	}
+
+	return app.RunSquash(ctx, cfg, through, stdout)
+}
+
+func printSquashUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe squash --through <version>")
+	fmt.Fprintln(w, "Replays every migration up to and including <version> in a temporary schema and writes the resulting schema")
+	fmt.Fprintln(w, "as <version>_squashed.sql, then moves the replaced files to the archive/ subdirectory of the migrations directory.")
+	fmt.Fprintln(w, "Databases that already applied them record the snapshot without running it; empty databases run only the snapshot.")
+}
+
+func runCheckSchemaCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	if len(args) > 0 && isHelp(args[0]) {
+		printCheckSchemaUsage(stdout)
+		return nil
+	}
+	// Usage and configuration errors exit with CheckSchemaExitFailed too.
+	if len(args) > 0 {
+		printCheckSchemaUsage(stderr)
+		return &app.ExitError{Code: app.CheckSchemaExitFailed, Err: fmt.Errorf("unexpected argument %s", args[0])}
+	}
+
+	cfg, err := config.LoadWithOptions(globals.loadOptions())
+	if err != nil {
+		return &app.ExitError{Code: app.CheckSchemaExitFailed, Err: err}
+	}
+
+	return app.RunCheckSchema(ctx, cfg, stdout)
+}
+
+func printCheckSchemaUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe check-schema")
+	fmt.Fprintln(w, "Compares the columns, constraints, indexes and views of the target schema with the fingerprint recorded by the")
+	fmt.Fprintln(w, "last tinytoe up that applied migrations, and lists everything added, removed or changed outside tinytoe.")
+	fmt.Fprintln(w, "Exits with 0 when the schema matches, 1 when it changed, 2 when it could not be checked.")
+}
+
+func runRebaseCommand(ctx context.Context, args []string, globals globalOptions, stdout, stderr io.Writer) error {
+	for len(args) > 0 && isHelp(args[0]) {
+		printRebaseUsage(stdout)
+		return nil
+	}
+
+	after := ""
+	dryRun := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case arg == "--dry-run":
+			dryRun = true
+		case name == "--after":
+			if !hasValue {
+				if i+1 >= len(args) {
+					printRebaseUsage(stderr)
+					return fmt.Errorf("--after requires a version")
+				}
+				i++
+				value = args[i]
+			}
+			after = strings.TrimSpace(value)
+			if after == "" {
+				printRebaseUsage(stderr)
+				return fmt.Errorf("--after requires a version")
+			}
+		case strings.HasPrefix(arg, "--"):
+			printRebaseUsage(stderr)
+			return fmt.Errorf("unknown flag %s", arg)
+		default:
+			printRebaseUsage(stderr)
+			return fmt.Errorf("unexpected argument %s", arg)
+		}
+	}
+
+	// With --after the files alone decide what moves.
+	requireDatabase := after == ""
+	opts := globals.loadOptions()
+	opts.RequireDatabase = &requireDatabase
+
+	cfg, err := config.LoadWithOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	return app.RunRebase(ctx, cfg, after, dryRun, stdout)
+}
+
+func printRebaseUsage(w io.Writer) {
+	if w == nil {
+		w = io.Discard
+	}
+	fmt.Fprintln(w, "Usage: tinytoe rebase [--after <version>] [--dry-run]")
+	fmt.Fprintln(w, "Gives the migrations not yet recorded in the target database fresh timestamps after the latest applied one,")
+	fmt.Fprintln(w, "keeping their relative order, then renames the files and rewrites the Version and Filename lines of their headers.")
+	fmt.Fprintln(w, "Use --after to rebase the migrations newer than <version> without connecting, and --dry-run to only print the new names.")
 }
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+// RunBaseline records every migration up to and including target as applied
+// without executing it, after confirming unless cfg.Force is set. Only
+// cfg.AllowExisting allows baselining a database that already records
+// migrations.
+func RunBaseline(ctx context.Context, cfg config.Config, target string, stdin io.Reader, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return fmt.Errorf("baseline version is required")
+	}
+
+	printer := newPrinter(cfg, stdout)
+
+	if err := requireMigrationsDir(cfg.MigrationsDir); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return err
+	}
+
+	// Validate the version before prompting so a typo fails fast.
+	baseline, err := migrator.BaselineMigrations(target)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Force {
+		if cfg.NonInteractive {
+			return fmt.Errorf("baseline requires confirmation but TINYTOE_NON_INTERACTIVE is set; rerun with --force to proceed")
+		}
+
+		ok, err := confirmBaseline(stdin, printer, len(baseline), target)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("baseline aborted by user")
+		}
+	}
+
+	result, err := migrator.Baseline(ctx, target, migrate.BaselineOptions{AllowExisting: cfg.AllowExisting})
+	if err != nil {
+		return err
+	}
+
+	recorded := make([]string, 0, len(result.Baselined))
+	for _, migration := range result.Baselined {
+		printer.PrintSuccessLine("Baselined %s", migration.Filename)
+		recorded = append(recorded, migration.Filename)
+	}
+	if len(recorded) > 0 {
+		printer.PrintBreak()
+	}
+
+	printer.PrintDelight(ui.Delight{
+		Command: "baseline",
+		Result:  fmt.Sprintf("database baselined at version %s", target),
+		Details: []ui.Detail{
+			{Label: "Target Schema", Value: cfg.TargetSchema},
+			{Label: "Baselined", Value: fmt.Sprintf("%d migration(s)", len(recorded))},
+			{Label: "Already Recorded", Value: fmt.Sprintf("%d migration(s)", len(result.Skipped))},
+		},
+		Data: map[string]interface{}{
+			"version":   target,
+			"baselined": recorded,
+		},
+	})
+
+	return nil
+}
+
+func confirmBaseline(stdin io.Reader, printer ui.Printer, count int, target string) (bool, error) {
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+
+	reader := bufio.NewReader(stdin)
+	printer.PrintWarning(fmt.Sprintf("This will record %d migration(s) through %s as applied without running them.", count, target))
+
+	prompt := fmt.Sprintf("Proceed with baselining at version %s? [y/N]: ", target)
+	printer.PrintPrompt(prompt)
+
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("read baseline confirmation: %w", err)
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	printer.PrintBreak()
+
+	return response == "y" || response == "yes", nil
+}
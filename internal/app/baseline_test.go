@@ -0,0 +1,141 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunBaselineRecordsWithoutExecuting(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_baseline_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+
+	// The baselined files would fail if executed.
+	files := map[string]string{
+		"20230101010101_legacy_one.sql": "SELECT * FROM table_that_does_not_exist;\n",
+		"20230101010202_legacy_two.sql": "SELECT * FROM table_that_does_not_exist;\n",
+		"20230101010303_new_table.sql":  "CREATE TABLE demo (id INT PRIMARY KEY);\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+		Force:         true,
+	}
+
+	var out bytes.Buffer
+	if err := app.RunBaseline(ctx, cfg, "20230101010202", nil, &out); err != nil {
+		t.Fatalf("RunBaseline: %v", err)
+	}
+	if !strings.Contains(out.String(), fmt.Sprintf("tinytoe baseline %s database baselined at version 20230101010202", ui.Arrow)) {
+		t.Fatalf("expected baseline summary, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "Baselined: 2 migration(s)") {
+		t.Fatalf("expected baselined count, got %q", out.String())
+	}
+
+	var baselined int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE baselined", qualify(schema, "tinytoe_migrations"))
+	if err := adminDB.QueryRowContext(ctx, query).Scan(&baselined); err != nil {
+		t.Fatalf("count baselined rows: %v", err)
+	}
+	if baselined != 2 {
+		t.Fatalf("expected 2 baselined rows, got %d", baselined)
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp after baseline: %v", err)
+	}
+
+	out.Reset()
+	if err := app.RunStatus(ctx, cfg, &out); err != nil {
+		t.Fatalf("RunStatus after baseline: %v", err)
+	}
+	if !strings.Contains(out.String(), "20230101010101_legacy_one.sql  baselined ") {
+		t.Fatalf("expected baselined state in status, got %q", out.String())
+	}
+
+	unforced := cfg
+	unforced.Force = false
+	err = app.RunBaseline(ctx, unforced, "20230101010202", strings.NewReader("y\n"), nil)
+	if err == nil || !strings.Contains(err.Error(), "already records 3 migration(s)") {
+		t.Fatalf("expected refusal when history exists, got %v", err)
+	}
+
+	// Skipping the prompt does not lift the refusal; only --allow-existing does.
+	err = app.RunBaseline(ctx, cfg, "20230101010202", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "unless --allow-existing is given") {
+		t.Fatalf("expected --force alone to be refused when history exists, got %v", err)
+	}
+	allowed := cfg
+	allowed.AllowExisting = true
+	out.Reset()
+	if err := app.RunBaseline(ctx, allowed, "20230101010202", nil, &out); err != nil {
+		t.Fatalf("RunBaseline with --allow-existing: %v", err)
+	}
+	if !strings.Contains(out.String(), "Already Recorded: 2 migration(s)") {
+		t.Fatalf("expected recorded versions to be skipped, got %q", out.String())
+	}
+}
+
+func TestRunBaselineRequiresConfirmationWhenNotForced(t *testing.T) {
+	migrationsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_legacy.sql"), []byte("SELECT 1;\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		MigrationsDir:  migrationsDir,
+		TargetSchema:   "public",
+		NonInteractive: true,
+	}
+
+	err := app.RunBaseline(context.Background(), cfg, "20230101010101", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "rerun with --force") {
+		t.Fatalf("expected non-interactive error, got %v", err)
+	}
+
+	err = app.RunBaseline(context.Background(), cfg, "20230101019999", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "does not match any migration") {
+		t.Fatalf("expected unknown version error, got %v", err)
+	}
+}
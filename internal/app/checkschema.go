@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+const (
+	// CheckSchemaExitChanged is returned by `tinytoe check-schema` when the
+	// schema changed outside Tiny Toe.
+	CheckSchemaExitChanged = 1
+	// CheckSchemaExitFailed is returned by `tinytoe check-schema` when the
+	// schema could not be checked, including when no fingerprint is recorded.
+	CheckSchemaExitFailed = 2
+)
+
+// RunCheckSchema compares the target schema's catalog with the fingerprint
+// recorded by the last `tinytoe up` and lists what changed outside Tiny Toe.
+// It returns an *ExitError carrying CheckSchemaExitChanged when anything
+// changed and CheckSchemaExitFailed when the check could not run.
+func RunCheckSchema(ctx context.Context, cfg config.Config, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return withExitCode(CheckSchemaExitFailed, err)
+	}
+	defer db.Close()
+
+	printer := newPrinter(cfg, stdout)
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return withExitCode(CheckSchemaExitFailed, err)
+	}
+
+	result, err := migrator.CheckSchema(ctx)
+	if err != nil {
+		return withExitCode(CheckSchemaExitFailed, fmt.Errorf("check schema: %w", err))
+	}
+	if !result.Recorded {
+		return withExitCode(CheckSchemaExitFailed, fmt.Errorf("no schema fingerprint is recorded for schema %q; run tinytoe up to record one", cfg.TargetSchema))
+	}
+
+	if len(result.Changes) > 0 {
+		printer.PrintRows(schemaChangeRows(result.Changes))
+		printer.PrintBreak()
+	}
+
+	outcome := "schema matches the last tinytoe up"
+	if len(result.Changes) > 0 {
+		outcome = fmt.Sprintf("%d change(s) made outside tinytoe", len(result.Changes))
+	}
+	printer.PrintDelight(ui.Delight{
+		Command: "check-schema",
+		Result:  outcome,
+		Details: []ui.Detail{
+			{Label: "Target Schema", Value: cfg.TargetSchema},
+			{Label: "Recorded At", Value: result.RecordedAt.UTC().Format(time.RFC3339)},
+			{Label: "Fingerprint", Value: fmt.Sprintf("%s (%d object(s))", result.Fingerprint, result.Objects)},
+		},
+		Data: map[string]interface{}{
+			"recorded_at": result.RecordedAt.UTC().Format(time.RFC3339),
+			"fingerprint": result.Fingerprint,
+			"changes":     result.Changes,
+		},
+	})
+
+	if len(result.Changes) > 0 {
+		return withExitCode(CheckSchemaExitChanged, fmt.Errorf("schema %q has %d change(s) made outside tinytoe; capture them in a migration or revert them", cfg.TargetSchema, len(result.Changes)))
+	}
+	return nil
+}
+
+// schemaChangeRows renders one warning row per change made outside tinytoe.
+func schemaChangeRows(changes []migrate.SchemaChange) []ui.Row {
+	rows := make([]ui.Row, 0, len(changes))
+	for _, change := range changes {
+		var detail string
+		switch change.Change {
+		case "added":
+			detail = oneLine(change.Current)
+		case "removed":
+			detail = oneLine(change.Recorded)
+		default:
+			detail = fmt.Sprintf("%s %s %s", oneLine(change.Recorded), ui.Arrow, oneLine(change.Current))
+		}
+		rows = append(rows, ui.Row{
+			Columns: []string{change.Change, change.Kind + " " + change.Name, detail},
+			Kind:    ui.DetailWarning,
+		})
+	}
+	return rows
+}
+
+// oneLine collapses a multi-line definition, such as a view body, for a row.
+func oneLine(definition string) string {
+	return strings.Join(strings.Fields(definition), " ")
+}
@@ -0,0 +1,130 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunCheckSchemaReportsOutOfBandChanges(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_fingerprint_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	migrationsDir := t.TempDir()
+	migration := "CREATE TABLE widgets (id INT PRIMARY KEY, name TEXT NOT NULL);\nCREATE INDEX widgets_name_idx ON widgets (name);\n"
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_widgets.sql"), []byte(migration), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	assertExitCode := func(err error, code int) {
+		t.Helper()
+		var exitErr *app.ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != code {
+			t.Fatalf("expected exit code %d, got %v", code, err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	assertExitCode(app.RunCheckSchema(ctx, cfg, &stdout), app.CheckSchemaExitFailed)
+
+	if err := app.RunUp(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+
+	stdout.Reset()
+	if err := app.RunCheckSchema(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunCheckSchema after up: %v\n%s", err, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "schema matches the last tinytoe up") {
+		t.Fatalf("expected a clean check, got %q", stdout.String())
+	}
+
+	for _, stmt := range []string{
+		fmt.Sprintf("ALTER TABLE %s.widgets ADD COLUMN note TEXT", quoteIdent(schema)),
+		fmt.Sprintf("ALTER TABLE %s.widgets ALTER COLUMN name DROP NOT NULL", quoteIdent(schema)),
+		fmt.Sprintf("DROP INDEX %s.widgets_name_idx", quoteIdent(schema)),
+	} {
+		if _, err := adminDB.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("change schema by hand: %v", err)
+		}
+	}
+
+	// A run that applies nothing must not bless the changes.
+	if err := app.RunUp(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunUp with nothing pending: %v", err)
+	}
+
+	stdout.Reset()
+	err = app.RunCheckSchema(ctx, cfg, &stdout)
+	assertExitCode(err, app.CheckSchemaExitChanged)
+	output := stdout.String()
+	for _, want := range []string{
+		"added    column widgets.note",
+		"changed  column widgets.name",
+		"text NOT NULL",
+		"removed  index widgets_name_idx",
+		"change(s) made outside tinytoe",
+	} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected %q in output, got %q", want, output)
+		}
+	}
+
+	// The next run that applies a migration reports the changes before its
+	// fingerprint absorbs them.
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010202_create_gadgets.sql"), []byte("CREATE TABLE gadgets (id INT);\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+	stdout.Reset()
+	if err := app.RunUp(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunUp with a pending migration: %v", err)
+	}
+	output = stdout.String()
+	for _, want := range []string{
+		fmt.Sprintf("Schema %q has 3 change(s) made outside tinytoe since the last run", schema),
+		"column widgets.note",
+		"index widgets_name_idx",
+	} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected %q in up output, got %q", want, output)
+		}
+	}
+
+	stdout.Reset()
+	if err := app.RunCheckSchema(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunCheckSchema after applying a migration: %v\n%s", err, stdout.String())
+	}
+}
@@ -3,21 +3,26 @@ package app
 import (
 	"bufio"
 	"context"
-	"database/sql"
 	"fmt"
 	"io"
 	"os"
 	"strings"
-	"time"
 
 	"tinytoe/internal/config"
 	"tinytoe/internal/ui"
-
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"tinytoe/migrate"
 )
 
-// RunDropAll drops the configured schema after confirmation.
+// RunDropAll drops the configured schema after confirmation. Protected
+// environments are refused unless cfg.AllowProtected names the database.
 func RunDropAll(ctx context.Context, cfg config.Config, stdin io.Reader, stdout io.Writer) error {
+	_, err := dropAll(ctx, cfg, stdin, stdout)
+	return err
+}
+
+// dropAll does the work of RunDropAll and reports whether the dropped schema
+// carried the protection marker.
+func dropAll(ctx context.Context, cfg config.Config, stdin io.Reader, stdout io.Writer) (bool, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -25,77 +30,102 @@ func RunDropAll(ctx context.Context, cfg config.Config, stdin io.Reader, stdout
 		stdout = io.Discard
 	}
 
-	if !cfg.Force {
-		if cfg.NonInteractive {
-			return fmt.Errorf("dropall requires confirmation but TINYTOE_NON_INTERACTIVE is set; rerun with --force to proceed")
-		}
+	printer := newPrinter(cfg, stdout)
 
-		ok, err := confirmDrop(stdin, stdout, cfg.TargetSchema)
-		if err != nil {
-			return err
-		}
-		if !ok {
-			return fmt.Errorf("dropall aborted by user")
-		}
+	if !cfg.Force && cfg.NonInteractive {
+		return false, fmt.Errorf("dropall requires confirmation but TINYTOE_NON_INTERACTIVE is set; rerun with --force to proceed")
 	}
 
-	db, err := sql.Open("pgx", cfg.DatabaseURL)
+	db, err := openDatabase(cfg)
 	if err != nil {
-		return fmt.Errorf("open database: %w", err)
+		return false, err
 	}
 	defer db.Close()
 
-	if err := pingDatabase(ctx, db); err != nil {
-		return err
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return false, err
 	}
 
-	if err := dropTargetSchema(ctx, db, cfg.TargetSchema); err != nil {
-		return err
+	// Protected environments are refused before prompting, whatever --force says.
+	marked, err := migrator.CheckDrop(ctx)
+	if err != nil {
+		return false, err
 	}
 
-	ui.NewPrinter(stdout).PrintDelight(ui.Delight{
+	if !cfg.Force {
+		summary, err := migrator.Summarize(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		ok, err := confirmDrop(stdin, printer, summary)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, fmt.Errorf("dropall aborted by user: the name typed did not match schema %q", cfg.TargetSchema)
+		}
+	}
+
+	if err := migrator.DropAll(ctx); err != nil {
+		return false, err
+	}
+
+	printer.PrintDelight(ui.Delight{
 		Command: "dropall",
 		Result:  fmt.Sprintf("schema %q dropped", cfg.TargetSchema),
+		Data:    map[string]interface{}{"schema": cfg.TargetSchema},
 	})
 
-	return nil
+	return marked, nil
 }
 
-func confirmDrop(stdin io.Reader, stdout io.Writer, schema string) (bool, error) {
+// confirmDrop shows what the drop would destroy and requires the schema name
+// to be typed back, so a stray keystroke cannot drop a schema.
+func confirmDrop(stdin io.Reader, printer ui.Printer, summary *migrate.SchemaSummary) (bool, error) {
 	if stdin == nil {
 		stdin = os.Stdin
 	}
-	if stdout == nil {
-		stdout = os.Stdout
-	}
 
 	reader := bufio.NewReader(stdin)
-	alert := fmt.Sprintf("This will drop the %q schema and erase all managed data.", schema)
-	ui.NewPrinter(stdout).PrintWarning(alert)
-
-	prompt := fmt.Sprintf("Proceed with dropping schema %q? [y/N]: ", schema)
-	if _, err := fmt.Fprint(stdout, prompt); err != nil {
-		return false, fmt.Errorf("prompt drop confirmation: %w", err)
+	alert := fmt.Sprintf("This will drop the %q schema in database %q and erase all managed data.", summary.Schema, summary.Database)
+	printer.PrintWarning(alert)
+
+	if summary.Exists {
+		printer.PrintRows([]ui.Row{
+			{Columns: []string{"Tables", fmt.Sprintf("%d", summary.Tables)}},
+			{Columns: []string{"Rows (approx.)", fmt.Sprintf("%d", summary.ApproxRows)}},
+			{Columns: []string{"Size", formatBytes(summary.SizeBytes)}},
+		})
+	} else {
+		printer.PrintRows([]ui.Row{{Columns: []string{"Schema", "does not exist yet"}}})
 	}
 
+	prompt := fmt.Sprintf("Type the schema name %q to confirm: ", summary.Schema)
+	printer.PrintPrompt(prompt)
+
 	response, err := reader.ReadString('\n')
 	if err != nil {
 		return false, fmt.Errorf("read drop confirmation: %w", err)
 	}
 
-	response = strings.TrimSpace(strings.ToLower(response))
-	fmt.Fprintln(stdout)
+	response = strings.TrimSpace(response)
+	printer.PrintBreak()
 
-	return response == "y" || response == "yes", nil
+	return response == summary.Schema, nil
 }
 
-func dropTargetSchema(parent context.Context, db *sql.DB, schema string) error {
-	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
-	defer cancel()
-
-	stmt := fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema))
-	if _, err := db.ExecContext(ctx, stmt); err != nil {
-		return fmt.Errorf("drop schema %q: %w", schema, err)
+// formatBytes renders a size using binary units, e.g. "12.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
-	return nil
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
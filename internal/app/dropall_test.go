@@ -83,27 +83,120 @@ func TestRunDropAllRequiresConfirmationWhenNotForced(t *testing.T) {
 	}
 }
 
-func TestRunDropAllAbortsWhenUserDeclines(t *testing.T) {
-	cfg := config.Config{
-		TargetSchema: "public",
+func TestRunDropAllRequiresTypedSchemaName(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
 	}
 
-	stdin := strings.NewReader("n\n")
-	var stdout bytes.Buffer
+	schema := fmt.Sprintf("tt_confirm_%d", time.Now().UnixNano())
+	ctx := context.Background()
 
-	err := app.RunDropAll(context.Background(), cfg, stdin, &stdout)
-	if err == nil {
-		t.Fatalf("expected error when user declines dropall")
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	if _, err := adminDB.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA %s", quoteIdent(schema))); err != nil {
+		t.Fatalf("create schema: %v", err)
 	}
-	if !strings.Contains(err.Error(), "dropall aborted by user") {
-		t.Fatalf("expected abort error, got %v", err)
+	for _, table := range []string{"demo", "other"} {
+		if _, err := adminDB.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s.%s (id INT)", quoteIdent(schema), table)); err != nil {
+			t.Fatalf("create table %s: %v", table, err)
+		}
+	}
+
+	cfg := config.Config{
+		DatabaseURL:  dsn,
+		TargetSchema: schema,
+	}
+
+	var stdout bytes.Buffer
+	err = app.RunDropAll(ctx, cfg, strings.NewReader("y\n"), &stdout)
+	if err == nil || !strings.Contains(err.Error(), "dropall aborted by user") {
+		t.Fatalf("expected a bare y to abort, got %v", err)
 	}
 
 	output := stdout.String()
-	if !strings.Contains(output, "drop the \"public\" schema") {
+	if !strings.Contains(output, fmt.Sprintf("drop the %q schema", schema)) {
 		t.Fatalf("expected warning in output, got %q", output)
 	}
-	if !strings.Contains(output, "Proceed with dropping schema \"public\"? [y/N]: ") {
+	if !strings.Contains(output, "Tables") || !strings.Contains(output, "2") || !strings.Contains(output, "Size") {
+		t.Fatalf("expected schema summary in output, got %q", output)
+	}
+	if !strings.Contains(output, fmt.Sprintf("Type the schema name %q to confirm: ", schema)) {
 		t.Fatalf("expected prompt in output, got %q", output)
 	}
+
+	stdout.Reset()
+	if err := app.RunDropAll(ctx, cfg, strings.NewReader(schema+"\n"), &stdout); err != nil {
+		t.Fatalf("RunDropAll with typed schema name: %v", err)
+	}
+
+	var count int
+	if err := adminDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM pg_namespace WHERE nspname = $1`, schema).Scan(&count); err != nil {
+		t.Fatalf("query schema existence: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected schema %s to be dropped, but it still exists", schema)
+	}
+}
+
+func TestRunDropAllRefusesProtectedSchema(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_protected_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: t.TempDir(),
+		TargetSchema:  schema,
+		Protected:     true,
+	}
+	if err := app.RunInit(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunInit: %v", err)
+	}
+
+	// The marker written by init protects the schema even once the config
+	// setting is gone.
+	cfg.Protected = false
+	cfg.Force = true
+	err = app.RunDropAll(ctx, cfg, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "is protected") {
+		t.Fatalf("expected protected schema to be refused, got %v", err)
+	}
+
+	var database string
+	if err := adminDB.QueryRowContext(ctx, `SELECT current_database()`).Scan(&database); err != nil {
+		t.Fatalf("query current database: %v", err)
+	}
+
+	cfg.AllowProtected = database + "_other"
+	if err := app.RunDropAll(ctx, cfg, nil, nil); err == nil {
+		t.Fatalf("expected override naming another database to be refused")
+	}
+
+	cfg.AllowProtected = database
+	if err := app.RunDropAll(ctx, cfg, nil, nil); err != nil {
+		t.Fatalf("RunDropAll with override: %v", err)
+	}
 }
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+// RunDump writes a snapshot of the target schema's DDL to the configured dump
+// file, schema.sql in the migrations directory by default.
+func RunDump(ctx context.Context, cfg config.Config, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	printer := newPrinter(cfg, stdout)
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return err
+	}
+
+	path, changed, err := writeSchemaDump(ctx, migrator, cfg)
+	if err != nil {
+		return err
+	}
+
+	result := "schema dump written"
+	if !changed {
+		result = "schema dump already up to date"
+	}
+	printer.PrintDelight(ui.Delight{
+		Command: "dump",
+		Result:  result,
+		Details: []ui.Detail{
+			{Label: "Target Schema", Value: cfg.TargetSchema},
+			{Label: "Dump File", Value: path},
+		},
+		Data: map[string]interface{}{
+			"path":    path,
+			"changed": changed,
+		},
+	})
+	return nil
+}
+
+// dumpPath resolves where the schema snapshot lives.
+func dumpPath(cfg config.Config) string {
+	if cfg.DumpFile != "" {
+		return cfg.DumpFile
+	}
+	return filepath.Join(cfg.MigrationsDir, migrate.SchemaDumpFile)
+}
+
+// dumpFileInMigrationsDir returns the name of the configured schema snapshot
+// when it sits directly in the migrations directory, where migration
+// discovery must skip it, and "" otherwise.
+func dumpFileInMigrationsDir(cfg config.Config) string {
+	dir, err := filepath.Abs(cfg.MigrationsDir)
+	if err != nil {
+		return ""
+	}
+	path, err := filepath.Abs(dumpPath(cfg))
+	if err != nil || filepath.Dir(path) != dir {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
+// writeSchemaDump renders the schema snapshot and writes it, leaving the file
+// untouched when its contents are already current. It reports the path and
+// whether the file changed.
+func writeSchemaDump(ctx context.Context, migrator *migrate.Migrator, cfg config.Config) (string, bool, error) {
+	path := dumpPath(cfg)
+
+	dump, err := migrator.Dump(ctx)
+	if err != nil {
+		return path, false, fmt.Errorf("dump schema: %w", err)
+	}
+
+	existing, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(existing, []byte(dump)) {
+		return path, false, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return path, false, fmt.Errorf("read schema dump: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(dump), 0o644); err != nil {
+		return path, false, fmt.Errorf("write schema dump: %w", err)
+	}
+	return path, true, nil
+}
@@ -0,0 +1,228 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunUpRefreshesDeterministicSchemaDump(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_dump_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	migrationsDir := t.TempDir()
+	write := func(name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+	write("20230101010101_create_widgets.sql", `
+CREATE SEQUENCE widget_numbers;
+CREATE TABLE widgets (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT 'unnamed',
+	serial BIGINT NOT NULL DEFAULT nextval('widget_numbers'),
+	CONSTRAINT widgets_name_present CHECK (name <> '')
+);
+CREATE INDEX widgets_name_idx ON widgets (name);
+CREATE FUNCTION widget_count() RETURNS BIGINT LANGUAGE sql AS $$ SELECT count(*) FROM widgets $$;
+`)
+	write("20230101010202_create_parts.sql", `
+CREATE TABLE parts (id INT PRIMARY KEY, widget_id BIGINT NOT NULL REFERENCES widgets (id));
+CREATE VIEW widget_parts AS SELECT w.name, p.id FROM widgets w JOIN parts p ON p.widget_id = w.id;
+CREATE VIEW all_widget_parts AS SELECT * FROM widget_parts;
+`)
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+		DumpOnUp:      true,
+	}
+
+	var stdout bytes.Buffer
+	if err := app.RunUp(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+	dumpFile := filepath.Join(migrationsDir, "schema.sql")
+	if !strings.Contains(stdout.String(), dumpFile) {
+		t.Fatalf("expected up to report the refreshed dump, got %q", stdout.String())
+	}
+
+	data, err := os.ReadFile(dumpFile)
+	if err != nil {
+		t.Fatalf("read schema dump: %v", err)
+	}
+	dump := string(data)
+	for _, want := range []string{
+		"CREATE SEQUENCE widget_numbers AS bigint",
+		"CREATE OR REPLACE FUNCTION widget_count()",
+		"CREATE TABLE widgets (\n    id bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,\n    name text DEFAULT 'unnamed'::text NOT NULL,",
+		"ADD CONSTRAINT widgets_pkey PRIMARY KEY (id);",
+		"ADD CONSTRAINT widgets_name_present CHECK (name <> ''::text);",
+		"ADD CONSTRAINT parts_widget_id_fkey FOREIGN KEY (widget_id) REFERENCES widgets(id);",
+		"CREATE INDEX widgets_name_idx ON widgets USING btree (name);",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("expected dump to contain %q, got:\n%s", want, dump)
+		}
+	}
+	if strings.Contains(dump, "tinytoe_migrations") || strings.Contains(dump, schema+".") {
+		t.Fatalf("expected bookkeeping tables and schema qualifiers to be left out, got:\n%s", dump)
+	}
+	if strings.Index(dump, "CREATE TABLE parts") > strings.Index(dump, "CREATE TABLE widgets") {
+		t.Fatalf("expected tables sorted by name, got:\n%s", dump)
+	}
+	if strings.Index(dump, "CREATE VIEW widget_parts") > strings.Index(dump, "CREATE VIEW all_widget_parts") {
+		t.Fatalf("expected views to follow the views they read from, got:\n%s", dump)
+	}
+
+	// schema.sql lives alongside the migrations without breaking discovery,
+	// and dumping an unchanged schema leaves the file as it is.
+	stdout.Reset()
+	if err := app.RunDump(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunDump: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "schema dump already up to date") {
+		t.Fatalf("expected an unchanged dump, got %q", stdout.String())
+	}
+	if err := app.RunUp(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunUp with schema.sql present: %v", err)
+	}
+}
+
+func TestConfiguredDumpFileIsNotAMigration(t *testing.T) {
+	migrationsDir := t.TempDir()
+	for name, body := range map[string]string{
+		"20230101010101_create_widgets.sql": "CREATE TABLE IF NOT EXISTS widgets (id INT);\n",
+		"structure.sql":                     "CREATE TABLE widgets (id INT);\n",
+	} {
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{MigrationsDir: migrationsDir, TargetSchema: "public"}
+	if err := app.RunLint(context.Background(), cfg, true, nil); err == nil || !strings.Contains(err.Error(), "invalid migration filename: structure.sql") {
+		t.Fatalf("expected an unconfigured dump file to be rejected, got %v", err)
+	}
+
+	cfg.DumpFile = filepath.Join(migrationsDir, "structure.sql")
+	if err := app.RunLint(context.Background(), cfg, true, nil); err != nil {
+		t.Fatalf("expected the configured dump file to be skipped, got %v", err)
+	}
+}
+
+func TestRunDumpReplaysIntoAnEmptySchema(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	suffix := time.Now().UnixNano()
+	original := fmt.Sprintf("tt_dump_original_%d", suffix)
+	replayed := fmt.Sprintf("tt_dump_replayed_%d", suffix)
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		for _, schema := range []string{original, replayed} {
+			_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+		}
+	})
+
+	// Function names sort before the tables and views their signatures and
+	// bodies depend on.
+	migrationsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_widgets.sql"), []byte(`
+CREATE TABLE widgets (id SERIAL PRIMARY KEY, name TEXT NOT NULL);
+CREATE FUNCTION active_widgets() RETURNS SETOF widgets LANGUAGE sql AS $$ SELECT * FROM widgets $$;
+CREATE FUNCTION label(w widgets) RETURNS TEXT LANGUAGE sql AS $$ SELECT w.name $$;
+CREATE FUNCTION count_widgets() RETURNS BIGINT LANGUAGE sql
+BEGIN ATOMIC
+  SELECT count(*) FROM widgets;
+END;
+CREATE VIEW widget_labels AS SELECT label(w) AS label FROM active_widgets() w;
+CREATE FUNCTION all_labels() RETURNS SETOF widget_labels LANGUAGE sql AS $$ SELECT * FROM widget_labels $$;
+`), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	originalCfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  original,
+		DumpFile:      filepath.Join(t.TempDir(), "original.sql"),
+	}
+	var stdout bytes.Buffer
+	if err := app.RunUp(ctx, originalCfg, &stdout); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+	if err := app.RunDump(ctx, originalCfg, &stdout); err != nil {
+		t.Fatalf("RunDump original: %v", err)
+	}
+	dump, err := os.ReadFile(originalCfg.DumpFile)
+	if err != nil {
+		t.Fatalf("read dump: %v", err)
+	}
+	if !strings.Contains(string(dump), "ALTER SEQUENCE widgets_id_seq OWNED BY widgets.id;") {
+		t.Fatalf("expected the serial sequence to stay owned by its column, got:\n%s", dump)
+	}
+
+	// The dump replays as a migration of its own and dumps back unchanged.
+	replayDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(replayDir, "20230101010101_snapshot.sql"), dump, 0o644); err != nil {
+		t.Fatalf("write snapshot migration: %v", err)
+	}
+	replayedCfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: replayDir,
+		TargetSchema:  replayed,
+		DumpFile:      filepath.Join(t.TempDir(), "replayed.sql"),
+	}
+	if err := app.RunUp(ctx, replayedCfg, &stdout); err != nil {
+		t.Fatalf("replay dump: %v\n%s", err, dump)
+	}
+	if err := app.RunDump(ctx, replayedCfg, &stdout); err != nil {
+		t.Fatalf("RunDump replayed: %v", err)
+	}
+	again, err := os.ReadFile(replayedCfg.DumpFile)
+	if err != nil {
+		t.Fatalf("read replayed dump: %v", err)
+	}
+	if string(again) != string(dump) {
+		t.Fatalf("expected the replayed schema to dump identically\noriginal:\n%s\nreplayed:\n%s", dump, again)
+	}
+}
@@ -0,0 +1,41 @@
+package app
+
+import "errors"
+
+// ExitUnreachable is the exit code for any command that gave up because the
+// database could not be reached (see migrate.UnreachableError).
+const ExitUnreachable = 3
+
+// ExitError carries a process exit code alongside the underlying error so the
+// CLI can distinguish outcomes such as pending migrations from hard failures.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return "exit status"
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode reports the process exit code associated with the error.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	return &ExitError{Code: code, Err: err}
+}
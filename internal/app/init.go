@@ -2,25 +2,15 @@ package app
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"io"
 	"os"
-	"time"
 
 	"tinytoe/internal/config"
 	"tinytoe/internal/ui"
-
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"tinytoe/migrate"
 )
 
-const migrationsTableDDL = `
-CREATE TABLE IF NOT EXISTS %s (
-	version VARCHAR(255) PRIMARY KEY,
-	filename VARCHAR(1024) NOT NULL,
-	applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
-)`
-
 // RunInit performs the work for `tinytoe init`.
 func RunInit(ctx context.Context, cfg config.Config, stdout io.Writer) error {
 	if ctx == nil {
@@ -31,25 +21,27 @@ func RunInit(ctx context.Context, cfg config.Config, stdout io.Writer) error {
 		return fmt.Errorf("ensure migrations directory: %w", err)
 	}
 
-	db, err := sql.Open("pgx", cfg.DatabaseURL)
+	db, err := openDatabase(cfg)
 	if err != nil {
-		return fmt.Errorf("open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
-	if err := pingDatabase(ctx, db); err != nil {
+	printer := newPrinter(cfg, stdout)
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
 		return err
 	}
 
-	if err := ensureTargetSchema(ctx, db, cfg.TargetSchema); err != nil {
+	if err := migrator.Init(ctx); err != nil {
 		return err
 	}
 
-	if err := ensureMigrationsTable(ctx, db, cfg.TargetSchema); err != nil {
-		return err
-	}
+	printInitialized(printer, cfg)
+	return nil
+}
 
-	printer := ui.NewPrinter(stdout)
+func printInitialized(printer ui.Printer, cfg config.Config) {
 	printer.PrintDelight(ui.Delight{
 		Command: "init",
 		Result:  "ready to migrate",
@@ -58,9 +50,11 @@ func RunInit(ctx context.Context, cfg config.Config, stdout io.Writer) error {
 			{Label: "Target Schema", Value: cfg.TargetSchema},
 			{Label: "Migrations Directory", Value: cfg.MigrationsDir},
 		},
+		Data: map[string]interface{}{
+			"target_schema":  cfg.TargetSchema,
+			"migrations_dir": cfg.MigrationsDir,
+		},
 	})
-
-	return nil
 }
 
 func ensureMigrationsDir(dir string) error {
@@ -70,34 +64,16 @@ func ensureMigrationsDir(dir string) error {
 	return os.MkdirAll(dir, 0o755)
 }
 
-func pingDatabase(parent context.Context, db *sql.DB) error {
-	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		return fmt.Errorf("connect to database: %w", err)
-	}
-	return nil
-}
-
-func ensureTargetSchema(parent context.Context, db *sql.DB, schema string) error {
-	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
-	defer cancel()
-
-	stmt := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdent(schema))
-	if _, err := db.ExecContext(ctx, stmt); err != nil {
-		return fmt.Errorf("ensure target schema %q: %w", schema, err)
+func requireMigrationsDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("migrations directory %s does not exist; run `toe init` first", dir)
+		}
+		return fmt.Errorf("stat migrations directory: %w", err)
 	}
-	return nil
-}
-
-func ensureMigrationsTable(parent context.Context, db *sql.DB, schema string) error {
-	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
-	defer cancel()
-
-	stmt := fmt.Sprintf(migrationsTableDDL, qualifyIdent(schema, "tinytoe_migrations"))
-	if _, err := db.ExecContext(ctx, stmt); err != nil {
-		return fmt.Errorf("create migrations table: %w", err)
+	if !info.IsDir() {
+		return fmt.Errorf("migrations path is not a directory: %s", dir)
 	}
 	return nil
 }
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+const (
+	// LintExitViolations is returned by `tinytoe lint` when rules are violated.
+	LintExitViolations = 1
+	// LintExitFailed is returned by `tinytoe lint` when the files or the
+	// database could not be checked.
+	LintExitFailed = 2
+)
+
+// RunLint checks pending migrations (every migration when all is set) for
+// dangerous DDL. It returns an *ExitError carrying LintExitViolations when
+// any rule is violated and LintExitFailed when linting could not run.
+func RunLint(ctx context.Context, cfg config.Config, all bool, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	printer := newPrinter(cfg, stdout)
+
+	if err := requireMigrationsDir(cfg.MigrationsDir); err != nil {
+		return withExitCode(LintExitFailed, err)
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return withExitCode(LintExitFailed, err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return withExitCode(LintExitFailed, err)
+	}
+
+	result, err := migrator.Lint(ctx, migrate.LintOptions{All: all, Disabled: cfg.LintDisabled})
+	if err != nil {
+		return withExitCode(LintExitFailed, err)
+	}
+
+	if len(result.Violations) > 0 {
+		rows := make([]ui.Row, 0, len(result.Violations))
+		for _, violation := range result.Violations {
+			rows = append(rows, ui.Row{
+				Columns: []string{fmt.Sprintf("%s:%d", violation.Migration.Filename, violation.Line), violation.Rule, violation.Message},
+				Kind:    ui.DetailWarning,
+			})
+		}
+		printer.PrintRows(rows)
+		printer.PrintBreak()
+	}
+
+	scope := "pending"
+	if all {
+		scope = "all"
+	}
+	outcome := "no problems found"
+	if len(result.Violations) > 0 {
+		outcome = fmt.Sprintf("%d problem(s) found", len(result.Violations))
+	}
+
+	printer.PrintDelight(ui.Delight{
+		Command: "lint",
+		Result:  outcome,
+		Details: []ui.Detail{
+			{Label: "Checked", Value: fmt.Sprintf("%d %s migration(s)", len(result.Checked), scope)},
+			{Label: "Migrations Directory", Value: cfg.MigrationsDir},
+		},
+		Data: map[string]interface{}{
+			"checked":    result.Checked,
+			"violations": result.Violations,
+		},
+	})
+
+	if len(result.Violations) > 0 {
+		return withExitCode(LintExitViolations, fmt.Errorf("lint found %d problem(s); fix them or suppress with -- tinytoe:lint-ignore=<rule>", len(result.Violations)))
+	}
+	return nil
+}
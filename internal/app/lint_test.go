@@ -0,0 +1,52 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+)
+
+func TestRunLintAllReportsViolationsWithExitCode(t *testing.T) {
+	migrationsDir := t.TempDir()
+	write := func(name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+	write("20230101010101_create_widgets.sql", "CREATE TABLE IF NOT EXISTS widgets (id INT);\n")
+
+	cfg := config.Config{MigrationsDir: migrationsDir, TargetSchema: "public"}
+
+	var stdout bytes.Buffer
+	if err := app.RunLint(context.Background(), cfg, true, &stdout); err != nil {
+		t.Fatalf("RunLint on clean migrations: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "no problems found") {
+		t.Fatalf("expected clean result, got %q", stdout.String())
+	}
+
+	write("20230101010202_index_widgets.sql", "\nCREATE INDEX IF NOT EXISTS widgets_id_idx ON widgets (id);\n")
+
+	stdout.Reset()
+	err := app.RunLint(context.Background(), cfg, true, &stdout)
+	var exitErr *app.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != app.LintExitViolations {
+		t.Fatalf("expected lint violations exit code, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "20230101010202_index_widgets.sql:2") || !strings.Contains(stdout.String(), "non-concurrent-index") {
+		t.Fatalf("expected file, line and rule in output, got %q", stdout.String())
+	}
+
+	cfg.LintDisabled = []string{"non-concurrent-index"}
+	if err := app.RunLint(context.Background(), cfg, true, nil); err != nil {
+		t.Fatalf("RunLint with rule disabled: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunUpWaitsForConcurrentRunner(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_lock_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+
+	slow := filepath.Join(migrationsDir, "20230101010101_slow.sql")
+	if err := os.WriteFile(slow, []byte("SELECT pg_sleep(3);\nCREATE TABLE demo (id INT PRIMARY KEY);\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	firstDone := make(chan error, 1)
+	go func() {
+		firstDone <- app.RunUp(ctx, cfg, nil)
+	}()
+
+	time.Sleep(time.Second)
+
+	impatient := cfg
+	impatient.LockWaitTimeout = 500 * time.Millisecond
+	var out bytes.Buffer
+	err = app.RunUp(ctx, impatient, &out)
+	if err == nil {
+		t.Fatalf("expected lock wait timeout while another runner holds the lock")
+	}
+	if !strings.Contains(err.Error(), "waiting for migration lock") || !strings.Contains(err.Error(), "held by PID") {
+		t.Fatalf("expected lock timeout naming the holder PID, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Waiting for migration lock") {
+		t.Fatalf("expected waiting message, got %q", out.String())
+	}
+
+	if err := <-firstDone; err != nil {
+		t.Fatalf("first RunUp: %v", err)
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp after lock released: %v", err)
+	}
+}
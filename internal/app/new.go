@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/user"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,6 +12,7 @@ import (
 
 	"tinytoe/internal/config"
 	"tinytoe/internal/ui"
+	"tinytoe/migrate"
 )
 
 // RunNew creates a new migration file using the provided description. It
@@ -27,7 +27,7 @@ func RunNew(cfg config.Config, description string, stdin io.Reader, stdout io.Wr
 		return "", err
 	}
 
-	printer := ui.NewPrinter(stdout)
+	printer := newPrinter(cfg, stdout)
 
 	existing, err := existingMigrationsForSlug(cfg.MigrationsDir, slug)
 	if err != nil {
@@ -42,7 +42,7 @@ func RunNew(cfg config.Config, description string, stdin io.Reader, stdout io.Wr
 			return "", fmt.Errorf("slug %q already exists; duplicate creation requires confirmation but TINYTOE_NON_INTERACTIVE is set", slug)
 		}
 
-		ok, err := confirmDuplicateSlug(stdin, stdout, slug)
+		ok, err := confirmDuplicateSlug(stdin, printer, slug)
 		if err != nil {
 			return "", err
 		}
@@ -65,11 +65,15 @@ func RunNew(cfg config.Config, description string, stdin io.Reader, stdout io.Wr
 	}
 	defer file.Close()
 
-	header := buildMigrationHeader(version, filename, now, createdBy())
+	header := buildMigrationHeader(version, filename, now, migrate.DefaultAppliedBy())
 	if _, err := file.WriteString(header); err != nil {
 		return "", fmt.Errorf("write migration header: %w", err)
 	}
 
+	if existing == nil {
+		existing = []string{}
+	}
+
 	details := []ui.Detail{
 		{Label: "Filename", Value: filename},
 	}
@@ -85,6 +89,12 @@ func RunNew(cfg config.Config, description string, stdin io.Reader, stdout io.Wr
 		Command: "new",
 		Result:  "migration created",
 		Details: details,
+		Data: map[string]interface{}{
+			"filename":   filename,
+			"path":       fullPath,
+			"version":    version,
+			"duplicates": existing,
+		},
 	})
 
 	return fullPath, nil
@@ -132,19 +142,14 @@ func existingMigrationsForSlug(dir, slug string) ([]string, error) {
 	return names, nil
 }
 
-func confirmDuplicateSlug(stdin io.Reader, stdout io.Writer, slug string) (bool, error) {
+func confirmDuplicateSlug(stdin io.Reader, printer ui.Printer, slug string) (bool, error) {
 	if stdin == nil {
 		stdin = os.Stdin
 	}
-	if stdout == nil {
-		stdout = os.Stdout
-	}
 
 	reader := bufio.NewReader(stdin)
 	prompt := fmt.Sprintf("Create another migration using slug %q? [y/N]: ", slug)
-	if _, err := fmt.Fprint(stdout, prompt); err != nil {
-		return false, fmt.Errorf("prompt duplicate confirmation: %w", err)
-	}
+	printer.PrintPrompt(prompt)
 
 	response, err := reader.ReadString('\n')
 	if err != nil {
@@ -152,7 +157,7 @@ func confirmDuplicateSlug(stdin io.Reader, stdout io.Writer, slug string) (bool,
 	}
 
 	response = strings.TrimSpace(strings.ToLower(response))
-	fmt.Fprintln(stdout)
+	printer.PrintBreak()
 
 	return response == "y" || response == "yes", nil
 }
@@ -197,34 +202,3 @@ func buildMigrationHeader(version, filename string, createdAt time.Time, created
 
 `, version, filename, createdAt.UTC().Format(time.RFC3339), createdBy)
 }
-
-func createdBy() string {
-	username := strings.TrimSpace(os.Getenv("USER"))
-	if username == "" {
-		username = strings.TrimSpace(os.Getenv("USERNAME"))
-	}
-	if username == "" {
-		if current, err := user.Current(); err == nil && current.Username != "" {
-			username = current.Username
-		}
-	}
-
-	hostname, err := os.Hostname()
-	if err != nil {
-		hostname = ""
-	}
-
-	username = strings.TrimSpace(username)
-	hostname = strings.TrimSpace(hostname)
-
-	switch {
-	case username != "" && hostname != "":
-		return fmt.Sprintf("%s@%s", username, hostname)
-	case username != "":
-		return username
-	case hostname != "":
-		return hostname
-	default:
-		return "unknown"
-	}
-}
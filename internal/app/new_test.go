@@ -2,6 +2,7 @@ package app_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -210,3 +211,37 @@ func TestRunNewSlugifiesComplexDescription(t *testing.T) {
 		t.Fatalf("expected slugified filename, got %s", filepath.Base(path))
 	}
 }
+
+func TestRunNewEmitsJSONWhenConfigured(t *testing.T) {
+	t.Setenv("USER", "tinytoe-test")
+
+	tempDir := t.TempDir()
+	cfg := config.Config{
+		MigrationsDir: tempDir,
+		Output:        ui.FormatJSON,
+	}
+
+	var out bytes.Buffer
+	path, err := app.RunNew(cfg, "Add Users Table", nil, &out)
+	if err != nil {
+		t.Fatalf("RunNew: %v", err)
+	}
+
+	var event struct {
+		Event   string `json:"event"`
+		Command string `json:"command"`
+		Data    struct {
+			Filename string `json:"filename"`
+			Path     string `json:"path"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &event); err != nil {
+		t.Fatalf("expected a single JSON event, got %q: %v", out.String(), err)
+	}
+	if event.Event != "result" || event.Command != "new" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if event.Data.Filename != filepath.Base(path) || event.Data.Path != path {
+		t.Fatalf("unexpected data: %+v", event.Data)
+	}
+}
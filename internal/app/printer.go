@@ -0,0 +1,81 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// cancelDeadlineDelay bounds how long a cancelled statement may take to stop
+// on the server before the connection is closed from the client side.
+const cancelDeadlineDelay = 5 * time.Second
+
+// newPrinter builds the printer for the configured output format.
+func newPrinter(cfg config.Config, w io.Writer) ui.Printer {
+	return ui.NewPrinterWithFormat(w, cfg.Output)
+}
+
+// openDatabase opens a handle for cfg.DatabaseURL. Connectivity is checked by
+// the migrator on first use.
+func openDatabase(cfg config.Config) (*sql.DB, error) {
+	connConfig, err := pgx.ParseConfig(cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	// Cancelling a context (e.g. on SIGINT) asks the server to cancel the
+	// running statement instead of dropping the connection, so the migration
+	// transaction rolls back right away rather than whenever the backend
+	// notices the client is gone.
+	connConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: cancelDeadlineDelay}
+	}
+	return stdlib.OpenDB(*connConfig), nil
+}
+
+// migratorOptions maps the CLI configuration onto migrate.Options, reporting
+// lock waits and applied files through printer.
+func migratorOptions(cfg config.Config, db *sql.DB, printer ui.Printer) migrate.Options {
+	return migrate.Options{
+		DB:               db,
+		MigrationsDir:    cfg.MigrationsDir,
+		DumpFile:         dumpFileInMigrationsDir(cfg),
+		TargetSchema:     cfg.TargetSchema,
+		ConnectTimeout:   cfg.ConnectTimeout,
+		LockWaitTimeout:  cfg.LockWaitTimeout,
+		LockTimeout:      cfg.LockTimeout,
+		StatementTimeout: cfg.StatementTimeout,
+		MigrationTimeout: cfg.MigrationTimeout,
+		Protected:        cfg.Protected,
+		AllowProtected:   cfg.AllowProtected,
+		AllowOutOfOrder:  cfg.AllowOutOfOrder,
+		Hooks: migrate.Hooks{
+			// The full connection error is reported if the wait times out.
+			ConnectRetry: func(attempt int, delay time.Duration, _ error) {
+				printer.PrintWarning(fmt.Sprintf("Waiting for the database to accept connections (attempt %d failed; retrying in %s)", attempt, delay.Round(100*time.Millisecond)))
+			},
+			LockWait: func(holder string) {
+				printer.PrintWarning(fmt.Sprintf("Waiting for migration lock on schema %q held by %s", cfg.TargetSchema, holder))
+			},
+			OutOfOrder: func(migration migrate.Migration, latest string) {
+				printer.PrintWarning(fmt.Sprintf("Applying %s out of order; %s is already applied", migration.Filename, latest))
+			},
+			Applied: func(migration migrate.Migration) {
+				printer.PrintSuccessLine("Applied %s", migration.Filename)
+			},
+			SchemaChanged: func(changes []migrate.SchemaChange) {
+				printer.PrintWarning(fmt.Sprintf("Schema %q has %d change(s) made outside tinytoe since the last run; the fingerprint recorded after this run will include them", cfg.TargetSchema, len(changes)))
+				printer.PrintRows(schemaChangeRows(changes))
+			},
+		},
+	}
+}
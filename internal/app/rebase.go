@@ -0,0 +1,189 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+// RunRebase gives the migrations not yet recorded in the target database, or
+// those newer than after when it is set, fresh timestamps following the latest
+// applied migration. Each file is renamed and the Version and Filename lines of
+// its header are rewritten. A dry run only prints the new names.
+func RunRebase(ctx context.Context, cfg config.Config, after string, dryRun bool, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	printer := newPrinter(cfg, stdout)
+
+	if err := requireMigrationsDir(cfg.MigrationsDir); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return err
+	}
+
+	result, err := migrator.Rebase(ctx, migrate.RebaseOptions{After: after})
+	if err != nil {
+		return fmt.Errorf("rebase migrations: %w", err)
+	}
+
+	if !dryRun {
+		if err := renameMigrations(cfg.MigrationsDir, result.Renames); err != nil {
+			return err
+		}
+	}
+
+	if len(result.Renames) > 0 {
+		rows := make([]ui.Row, 0, len(result.Renames))
+		for _, rename := range result.Renames {
+			rows = append(rows, ui.Row{Columns: []string{rename.From.Filename, ui.Arrow, rename.To.Filename}})
+		}
+		printer.PrintRows(rows)
+		printer.PrintBreak()
+	}
+
+	outcome := fmt.Sprintf("rebased %d migration(s)", len(result.Renames))
+	switch {
+	case len(result.Renames) == 0:
+		outcome = "no migrations to rebase"
+	case dryRun:
+		outcome = fmt.Sprintf("dry run: would rebase %d migration(s); no files changed", len(result.Renames))
+	}
+	details := []ui.Detail{
+		{Label: "Migrations Directory", Value: cfg.MigrationsDir},
+	}
+	if result.Latest != "" {
+		details = append(details, ui.Detail{Label: "After", Value: result.Latest})
+	}
+	printer.PrintDelight(ui.Delight{
+		Command: "rebase",
+		Result:  outcome,
+		Details: details,
+		Data: map[string]interface{}{
+			"after":   result.Latest,
+			"renames": result.Renames,
+			"dry_run": dryRun,
+		},
+	})
+	return nil
+}
+
+// renameMigrations moves each migration to its new filename with a rewritten
+// header. The renames are first replayed against the versions in the
+// directory, so a new version already taken by another file, at any step,
+// leaves the directory untouched.
+func renameMigrations(dir string, renames []migrate.RebaseRename) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read migrations directory: %w", err)
+	}
+	versions := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if version, ok := filenameVersion(entry.Name()); ok && !entry.IsDir() {
+			versions[version] = entry.Name()
+		}
+	}
+	for _, rename := range renames {
+		if rename.To.Filename == rename.From.Filename {
+			continue
+		}
+		if versions[rename.From.Version] == rename.From.Filename {
+			delete(versions, rename.From.Version)
+		}
+		if owner, ok := versions[rename.To.Version]; ok {
+			return fmt.Errorf("cannot rebase %s: version %s is already used by %s", rename.From.Filename, rename.To.Version, owner)
+		}
+		versions[rename.To.Version] = rename.To.Filename
+	}
+
+	for _, rename := range renames {
+		if rename.To.Filename == rename.From.Filename {
+			continue
+		}
+		from := filepath.Join(dir, rename.From.Filename)
+		to := filepath.Join(dir, rename.To.Filename)
+
+		info, err := os.Stat(from)
+		if err != nil {
+			return fmt.Errorf("rebase %s: %w", rename.From.Filename, err)
+		}
+		data, err := os.ReadFile(from)
+		if err != nil {
+			return fmt.Errorf("read migration %s: %w", rename.From.Filename, err)
+		}
+		// Write the rewritten body under the old name first, so the rename is
+		// the only step that changes which files exist.
+		data = rewriteMigrationHeader(data, rename.To.Version, rename.To.Filename)
+		if err := os.WriteFile(from, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("rewrite migration header of %s: %w", rename.From.Filename, err)
+		}
+		if err := os.Rename(from, to); err != nil {
+			return fmt.Errorf("rename %s to %s: %w", rename.From.Filename, rename.To.Filename, err)
+		}
+	}
+	return nil
+}
+
+// filenameVersion returns the version of a migration filename, as discovered
+// by the migrator.
+func filenameVersion(name string) (string, bool) {
+	if !strings.HasSuffix(name, ".sql") || len(name) < 15 || name[14] != '_' || strings.Trim(name[:14], "0123456789") != "" {
+		return "", false
+	}
+	return name[:14], true
+}
+
+// rewriteMigrationHeader replaces the Version and Filename lines written by
+// buildMigrationHeader. Only the leading comment block is searched, so the
+// SQL body is never touched; files without a header are returned unchanged.
+func rewriteMigrationHeader(data []byte, version, filename string) []byte {
+	replacements := map[string]string{
+		"-- Version: ":  version,
+		"-- Filename: ": filename,
+	}
+
+	var out bytes.Buffer
+	rest := data
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		if !bytes.HasPrefix(line, []byte("--")) {
+			break
+		}
+		rest = rest[len(line):]
+
+		for prefix, value := range replacements {
+			if bytes.HasPrefix(line, []byte(prefix)) {
+				ending := line[len(bytes.TrimRight(line, "\r\n")):]
+				line = append([]byte(prefix+value), ending...)
+				break
+			}
+		}
+		out.Write(line)
+	}
+	out.Write(rest)
+	return out.Bytes()
+}
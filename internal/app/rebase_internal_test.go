@@ -0,0 +1,88 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"tinytoe/migrate"
+)
+
+func TestRenameMigrationsChecksVersions(t *testing.T) {
+	rename := func(from, to string) migrate.RebaseRename {
+		return migrate.RebaseRename{
+			From: migrate.Migration{Version: from[:14], Filename: from},
+			To:   migrate.Migration{Version: to[:14], Filename: to},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		files   []string
+		renames []migrate.RebaseRename
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "version taken by a file that stays",
+			files:   []string{"20230101010101_other.sql", "20230101010202_mine.sql"},
+			renames: []migrate.RebaseRename{rename("20230101010202_mine.sql", "20230101010101_mine.sql")},
+			wantErr: "version 20230101010101 is already used by 20230101010101_other.sql",
+		},
+		{
+			name:  "version freed by an earlier rename",
+			files: []string{"20230101010202_b.sql", "20230101010505_a.sql"},
+			renames: []migrate.RebaseRename{
+				rename("20230101010202_b.sql", "20230101010101_b.sql"),
+				rename("20230101010505_a.sql", "20230101010202_a.sql"),
+			},
+			want: []string{"20230101010101_b.sql", "20230101010202_a.sql"},
+		},
+		{
+			name:  "version still held by a later rename",
+			files: []string{"20230101010101_a.sql", "20230101010202_b.sql"},
+			renames: []migrate.RebaseRename{
+				rename("20230101010101_a.sql", "20230101010202_a.sql"),
+				rename("20230101010202_b.sql", "20230101010303_b.sql"),
+			},
+			wantErr: "version 20230101010202 is already used by 20230101010202_b.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;\n"), 0o644); err != nil {
+					t.Fatalf("write %s: %v", name, err)
+				}
+			}
+
+			err := renameMigrations(dir, tt.renames)
+			want := tt.want
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				want = tt.files
+			} else if err != nil {
+				t.Fatalf("renameMigrations: %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("read dir: %v", err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(want, ",") {
+				t.Fatalf("expected files %v, got %v", want, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunRebaseAfterRenamesNewerMigrationsAndRewritesHeaders(t *testing.T) {
+	migrationsDir := t.TempDir()
+	files := map[string]string{
+		"20230101010101_create_widgets.sql": "-- Tiny Toe Migration\n-- Version: 20230101010101\n-- Filename: 20230101010101_create_widgets.sql\n\nCREATE TABLE widgets (id INT);\n",
+		"20230101010202_add_name.sql":       "-- Tiny Toe Migration\n-- Version: 20230101010202\n-- Filename: 20230101010202_add_name.sql\n-- Created By: dev@host\n\n-- Version: not a header\nALTER TABLE widgets ADD COLUMN name TEXT;\n",
+		"20230101010303_add_index.sql":      "CREATE INDEX widgets_name_idx ON widgets (name);\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{MigrationsDir: migrationsDir, TargetSchema: "public"}
+
+	var stdout bytes.Buffer
+	if err := app.RunRebase(context.Background(), cfg, "20230101010101", true, &stdout); err != nil {
+		t.Fatalf("RunRebase dry run: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "would rebase 2 migration(s)") {
+		t.Fatalf("expected dry run summary, got %q", stdout.String())
+	}
+	if _, err := os.Stat(filepath.Join(migrationsDir, "20230101010202_add_name.sql")); err != nil {
+		t.Fatalf("expected dry run to leave files in place: %v", err)
+	}
+
+	stdout.Reset()
+	if err := app.RunRebase(context.Background(), cfg, "20230101010101", false, &stdout); err != nil {
+		t.Fatalf("RunRebase: %v", err)
+	}
+
+	entries, err := os.ReadDir(migrationsDir)
+	if err != nil {
+		t.Fatalf("read migrations dir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "20230101010101_create_widgets.sql" {
+		t.Fatalf("expected the first migration to keep its name, got %v", names)
+	}
+	if !strings.HasSuffix(names[1], "_add_name.sql") || !strings.HasSuffix(names[2], "_add_index.sql") {
+		t.Fatalf("expected the rebased migrations to keep their relative order, got %v", names)
+	}
+	if names[1] <= "20230101010303" {
+		t.Fatalf("expected fresh timestamps, got %v", names)
+	}
+
+	version := names[1][:14]
+	data, err := os.ReadFile(filepath.Join(migrationsDir, names[1]))
+	if err != nil {
+		t.Fatalf("read rebased migration: %v", err)
+	}
+	want := "-- Tiny Toe Migration\n-- Version: " + version + "\n-- Filename: " + names[1] + "\n-- Created By: dev@host\n\n-- Version: not a header\nALTER TABLE widgets ADD COLUMN name TEXT;\n"
+	if string(data) != want {
+		t.Fatalf("expected header to be rewritten in place, got:\n%s", data)
+	}
+
+	data, err = os.ReadFile(filepath.Join(migrationsDir, names[2]))
+	if err != nil {
+		t.Fatalf("read rebased migration: %v", err)
+	}
+	if string(data) != files["20230101010303_add_index.sql"] {
+		t.Fatalf("expected a migration without a header to keep its contents, got %q", data)
+	}
+
+	if err := app.RunRebase(context.Background(), cfg, "2023", false, nil); err == nil || !strings.Contains(err.Error(), "14-digit") {
+		t.Fatalf("expected invalid version error, got %v", err)
+	}
+}
+
+func TestRunRebaseMovesUnappliedMigrationsAfterTheLatestApplied(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_rebase_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	migrationsDir := t.TempDir()
+	write := func(name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{DatabaseURL: dsn, MigrationsDir: migrationsDir, TargetSchema: schema}
+
+	write("20230101010101_create_widgets.sql", "CREATE TABLE widgets (id INT);\n")
+	if err := app.RunRebase(ctx, cfg, "", false, nil); err == nil || !strings.Contains(err.Error(), "no applied migrations") {
+		t.Fatalf("expected rebase without applied migrations to be refused, got %v", err)
+	}
+
+	write("20230101010303_create_gadgets.sql", "CREATE TABLE gadgets (id INT);\n")
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+
+	// Another branch's migrations: one older than the latest applied, one newer.
+	write("20230101010202_add_widget_name.sql", "-- Tiny Toe Migration\n-- Version: 20230101010202\n-- Filename: 20230101010202_add_widget_name.sql\n\nALTER TABLE widgets ADD COLUMN name TEXT;\n")
+	write("20230101010404_add_gadget_name.sql", "ALTER TABLE gadgets ADD COLUMN name TEXT;\n")
+
+	if err := app.RunRebase(ctx, cfg, "", false, nil); err != nil {
+		t.Fatalf("RunRebase: %v", err)
+	}
+
+	entries, err := os.ReadDir(migrationsDir)
+	if err != nil {
+		t.Fatalf("read migrations dir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if len(names) != 4 || names[0] != "20230101010101_create_widgets.sql" || names[1] != "20230101010303_create_gadgets.sql" {
+		t.Fatalf("expected applied migrations to keep their names, got %v", names)
+	}
+	if !strings.HasSuffix(names[2], "_add_widget_name.sql") || !strings.HasSuffix(names[3], "_add_gadget_name.sql") {
+		t.Fatalf("expected unapplied migrations after the applied ones in their original order, got %v", names)
+	}
+	data, err := os.ReadFile(filepath.Join(migrationsDir, names[2]))
+	if err != nil {
+		t.Fatalf("read rebased migration: %v", err)
+	}
+	if !strings.Contains(string(data), "-- Version: "+names[2][:14]+"\n-- Filename: "+names[2]+"\n") {
+		t.Fatalf("expected rewritten header, got:\n%s", data)
+	}
+
+	// The rebased chain applies in order without --allow-out-of-order.
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp after rebase: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	if err := app.RunRebase(ctx, cfg, "", false, &stdout); err != nil {
+		t.Fatalf("RunRebase with nothing unapplied: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "no migrations to rebase") {
+		t.Fatalf("expected nothing to rebase, got %q", stdout.String())
+	}
+}
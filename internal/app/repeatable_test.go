@@ -0,0 +1,111 @@
+package app_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunUpReappliesChangedRepeatableMigrations(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_repeatable_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	repeatableDir := filepath.Join(migrationsDir, "repeatable")
+	if err := os.MkdirAll(repeatableDir, 0o755); err != nil {
+		t.Fatalf("mkdir repeatable dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_items.sql"), []byte("CREATE TABLE items (id INT PRIMARY KEY, price INT);\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+	viewPath := filepath.Join(repeatableDir, "items_view.sql")
+	if err := os.WriteFile(viewPath, []byte("CREATE OR REPLACE VIEW items_view AS SELECT id FROM items;\n"), 0o644); err != nil {
+		t.Fatalf("write repeatable migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+
+	repeatableChecksum := func() string {
+		t.Helper()
+		var sum string
+		query := fmt.Sprintf(`SELECT checksum FROM %s WHERE name = 'repeatable/items_view.sql'`, qualify(schema, "tinytoe_repeatable_migrations"))
+		if err := adminDB.QueryRowContext(ctx, query).Scan(&sum); err != nil {
+			t.Fatalf("query repeatable checksum: %v", err)
+		}
+		return sum
+	}
+	first := repeatableChecksum()
+
+	if err := app.RunStatus(ctx, cfg, nil); err != nil {
+		t.Fatalf("expected status to be clean after up, got %v", err)
+	}
+
+	if err := os.WriteFile(viewPath, []byte("CREATE OR REPLACE VIEW items_view AS SELECT id, price FROM items;\n"), 0o644); err != nil {
+		t.Fatalf("rewrite repeatable migration: %v", err)
+	}
+
+	err = app.RunStatus(ctx, cfg, nil)
+	var exitErr *app.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != app.StatusExitPending {
+		t.Fatalf("expected pending exit code for changed repeatable, got %v", err)
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp after change: %v", err)
+	}
+	if second := repeatableChecksum(); second == first {
+		t.Fatalf("expected checksum to be updated after re-apply")
+	}
+
+	var columns int
+	query := `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = $1 AND table_name = 'items_view'`
+	if err := adminDB.QueryRowContext(ctx, query, schema).Scan(&columns); err != nil {
+		t.Fatalf("count view columns: %v", err)
+	}
+	if columns != 2 {
+		t.Fatalf("expected re-applied view to expose 2 columns, got %d", columns)
+	}
+
+	var versioned int
+	if err := adminDB.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %s`, qualify(schema, "tinytoe_migrations"))).Scan(&versioned); err != nil {
+		t.Fatalf("count versioned migrations: %v", err)
+	}
+	if versioned != 1 {
+		t.Fatalf("expected repeatable migrations to stay out of tinytoe_migrations, got %d rows", versioned)
+	}
+}
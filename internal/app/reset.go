@@ -10,6 +10,7 @@ import (
 )
 
 // RunReset drops and recreates the target schema, then reapplies all migrations.
+// A protection marker found in the dropped schema is recorded again.
 func RunReset(ctx context.Context, cfg config.Config, stdin io.Reader, stdout io.Writer) error {
 	if ctx == nil {
 		ctx = context.Background()
@@ -19,9 +20,12 @@ func RunReset(ctx context.Context, cfg config.Config, stdin io.Reader, stdout io
 		return err
 	}
 
-	if err := RunDropAll(ctx, cfg, stdin, stdout); err != nil {
+	marked, err := dropAll(ctx, cfg, stdin, stdout)
+	if err != nil {
 		return err
 	}
+	// Init and up record the marker in the recreated schema.
+	cfg.Protected = cfg.Protected || marked
 
 	if err := RunInit(ctx, cfg, stdout); err != nil {
 		return err
@@ -160,34 +160,34 @@ func TestRunResetRequiresConfirmationWhenNotForced(t *testing.T) {
 	}
 }
 
-func TestRunResetAbortsWhenUserDeclines(t *testing.T) {
-	tempDir := t.TempDir()
-	migrationsDir := filepath.Join(tempDir, "migrations")
+func TestRunResetAbortsWhenSchemaNameDoesNotMatch(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_reset_confirm_%d", time.Now().UnixNano())
+	migrationsDir := filepath.Join(t.TempDir(), "migrations")
 	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
 		t.Fatalf("mkdir migrations dir: %v", err)
 	}
 
 	cfg := config.Config{
+		DatabaseURL:   dsn,
 		MigrationsDir: migrationsDir,
-		TargetSchema:  "public",
+		TargetSchema:  schema,
 	}
 
-	stdin := strings.NewReader("n\n")
+	stdin := strings.NewReader("public\n")
 	var stdout bytes.Buffer
 
 	err := app.RunReset(context.Background(), cfg, stdin, &stdout)
-	if err == nil {
-		t.Fatalf("expected error when user declines reset")
-	}
-	if !strings.Contains(err.Error(), "dropall aborted by user") {
+	if err == nil || !strings.Contains(err.Error(), "dropall aborted by user") {
 		t.Fatalf("expected dropall abort error, got %v", err)
 	}
 
 	output := stdout.String()
-	if !strings.Contains(output, "drop the \"public\" schema") {
-		t.Fatalf("expected warning in output, got %q", output)
-	}
-	if !strings.Contains(output, "Proceed with dropping schema \"public\"? [y/N]: ") {
+	if !strings.Contains(output, fmt.Sprintf("Type the schema name %q to confirm: ", schema)) {
 		t.Fatalf("expected prompt in output, got %q", output)
 	}
 }
@@ -195,3 +195,66 @@ func TestRunResetAbortsWhenUserDeclines(t *testing.T) {
 func qualify(schema, name string) string {
 	return quoteIdent(schema) + "." + quoteIdent(name)
 }
+
+func TestRunResetKeepsProtectionMarker(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_reset_protected_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	migrationsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_widgets.sql"), []byte("CREATE TABLE widgets (id INT);\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+		Protected:     true,
+		Force:         true,
+	}
+	if err := app.RunInit(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunInit: %v", err)
+	}
+
+	var database string
+	if err := adminDB.QueryRowContext(ctx, `SELECT current_database()`).Scan(&database); err != nil {
+		t.Fatalf("query current database: %v", err)
+	}
+
+	// Only the marker protects the schema now; an authorised reset must not
+	// leave it unprotected.
+	cfg.Protected = false
+	cfg.AllowProtected = database
+	if err := app.RunReset(ctx, cfg, nil, nil); err != nil {
+		t.Fatalf("RunReset with override: %v", err)
+	}
+
+	var value string
+	query := fmt.Sprintf("SELECT value FROM %s WHERE name = 'protected'", qualify(schema, "tinytoe_settings"))
+	if err := adminDB.QueryRowContext(ctx, query).Scan(&value); err != nil {
+		t.Fatalf("read protection marker after reset: %v", err)
+	}
+	if value != "true" {
+		t.Fatalf("expected protection marker after reset, got %q", value)
+	}
+
+	cfg.AllowProtected = ""
+	if err := app.RunDropAll(ctx, cfg, nil, nil); err == nil || !strings.Contains(err.Error(), "is protected") {
+		t.Fatalf("expected reset schema to stay protected, got %v", err)
+	}
+}
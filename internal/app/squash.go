@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+// squashArchiveDir is the subdirectory of the migrations directory that
+// receives the files replaced by a squash snapshot. Migration discovery
+// ignores subdirectories other than repeatable/.
+const squashArchiveDir = "archive"
+
+// RunSquash replaces every migration through the given version with a single
+// snapshot migration, moves the replaced files to the archive directory, and
+// records the squash in the target database.
+func RunSquash(ctx context.Context, cfg config.Config, through string, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	if err := requireMigrationsDir(cfg.MigrationsDir); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	printer := newPrinter(cfg, stdout)
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return err
+	}
+
+	result, err := migrator.Squash(ctx, through)
+	if err != nil {
+		return fmt.Errorf("squash migrations: %w", err)
+	}
+
+	archiveDir := filepath.Join(cfg.MigrationsDir, squashArchiveDir)
+	if err := writeSquash(cfg.MigrationsDir, archiveDir, result); err != nil {
+		return err
+	}
+
+	recorded, err := migrator.RecordSquash(ctx, result)
+	if err != nil {
+		return fmt.Errorf("record squash (the snapshot and archive are already in place; the next tinytoe up records it): %w", err)
+	}
+
+	database := "records the squashed range as applied"
+	if !recorded {
+		database = "has not applied the squashed range and will run the snapshot"
+	}
+	printer.PrintDelight(ui.Delight{
+		Command: "squash",
+		Result:  fmt.Sprintf("squashed %d migration(s) into %s", len(result.Squashed), result.Filename),
+		Details: []ui.Detail{
+			{Label: "Through", Value: result.Version},
+			{Label: "Archived To", Value: archiveDir},
+			{Label: "Database", Value: database},
+		},
+		Data: map[string]interface{}{
+			"snapshot": result.Filename,
+			"squashed": result.Squashed,
+			"archive":  archiveDir,
+			"recorded": recorded,
+		},
+	})
+	return nil
+}
+
+// writeSquash writes the snapshot next to the migrations it replaces, then
+// moves those into archiveDir. Every destination is checked first so a
+// conflict leaves the directory untouched.
+func writeSquash(migrationsDir, archiveDir string, result *migrate.SquashResult) error {
+	snapshotPath := filepath.Join(migrationsDir, result.Filename)
+	if _, err := os.Stat(snapshotPath); err == nil {
+		return fmt.Errorf("snapshot already exists: %s", result.Filename)
+	}
+	for _, migration := range result.Squashed {
+		if _, err := os.Stat(filepath.Join(archiveDir, migration.Filename)); err == nil {
+			return fmt.Errorf("%s is already in %s", migration.Filename, archiveDir)
+		}
+	}
+
+	if err := os.MkdirAll(archiveDir, 0o755); err != nil {
+		return fmt.Errorf("create archive directory: %w", err)
+	}
+
+	header := buildMigrationHeader(result.Version, result.Filename, time.Now(), migrate.DefaultAppliedBy())
+	if err := os.WriteFile(snapshotPath, []byte(header+result.SQL), 0o644); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	for _, migration := range result.Squashed {
+		from := filepath.Join(migrationsDir, migration.Filename)
+		if err := os.Rename(from, filepath.Join(archiveDir, migration.Filename)); err != nil {
+			return fmt.Errorf("archive %s: %w", migration.Filename, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+	"tinytoe/migrate"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunSquashKeepsExistingDatabasesCurrent(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	suffix := time.Now().UnixNano()
+	existing := fmt.Sprintf("tt_squash_existing_%d", suffix)
+	fresh := fmt.Sprintf("tt_squash_fresh_%d", suffix)
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		for _, schema := range []string{existing, fresh} {
+			_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+		}
+	})
+
+	migrationsDir := t.TempDir()
+	files := map[string]string{
+		"20230101010101_create_widgets.sql": "CREATE TABLE widgets (id INT PRIMARY KEY);\n",
+		"20230101010202_add_name.sql":       "ALTER TABLE widgets ADD COLUMN name TEXT NOT NULL DEFAULT 'unnamed';\n",
+		"20230101010303_create_parts.sql":   "CREATE TABLE parts (id INT PRIMARY KEY, widget_id INT REFERENCES widgets (id));\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+
+	existingCfg := config.Config{DatabaseURL: dsn, MigrationsDir: migrationsDir, TargetSchema: existing}
+	freshCfg := config.Config{DatabaseURL: dsn, MigrationsDir: migrationsDir, TargetSchema: fresh}
+
+	var stdout bytes.Buffer
+	if err := app.RunUp(ctx, existingCfg, &stdout); err != nil {
+		t.Fatalf("RunUp before squash: %v", err)
+	}
+
+	stdout.Reset()
+	if err := app.RunSquash(ctx, existingCfg, "20230101010202", &stdout); err != nil {
+		t.Fatalf("RunSquash: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "squashed 2 migration(s) into 20230101010202_squashed.sql") {
+		t.Fatalf("expected squash summary, got %q", stdout.String())
+	}
+
+	snapshot, err := os.ReadFile(filepath.Join(migrationsDir, "20230101010202_squashed.sql"))
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	for _, want := range []string{"-- Version: 20230101010202", "-- tinytoe:squash", "CREATE TABLE widgets (", "name text DEFAULT 'unnamed'::text NOT NULL"} {
+		if !strings.Contains(string(snapshot), want) {
+			t.Fatalf("expected snapshot to contain %q, got:\n%s", want, snapshot)
+		}
+	}
+	for _, name := range []string{"20230101010101_create_widgets.sql", "20230101010202_add_name.sql"} {
+		if _, err := os.Stat(filepath.Join(migrationsDir, "archive", name)); err != nil {
+			t.Fatalf("expected %s to be archived: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(migrationsDir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be moved out of the migrations directory", name)
+		}
+	}
+
+	var squashed int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s.tinytoe_migrations WHERE squashed_into = '20230101010202_squashed.sql'", quoteIdent(existing))
+	if err := adminDB.QueryRowContext(ctx, query).Scan(&squashed); err != nil {
+		t.Fatalf("count squashed rows: %v", err)
+	}
+	if squashed != 2 {
+		t.Fatalf("expected the 2 squashed rows to be marked, got %d", squashed)
+	}
+
+	// The database that applied the originals reports no drift.
+	stdout.Reset()
+	if err := app.RunStatus(ctx, existingCfg, &stdout); err != nil {
+		t.Fatalf("RunStatus after squash: %v\n%s", err, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "20230101010202_squashed.sql") || !strings.Contains(stdout.String(), "squashed ") {
+		t.Fatalf("expected the snapshot to be listed as squashed, got %q", stdout.String())
+	}
+	if err := app.RunUp(ctx, existingCfg, &stdout); err != nil {
+		t.Fatalf("RunUp after squash: %v", err)
+	}
+
+	// An empty database runs only the snapshot and the later migration.
+	stdout.Reset()
+	if err := app.RunUp(ctx, freshCfg, &stdout); err != nil {
+		t.Fatalf("RunUp on an empty database: %v", err)
+	}
+	var applied int
+	query = fmt.Sprintf("SELECT COUNT(*) FROM %s.tinytoe_migrations", quoteIdent(fresh))
+	if err := adminDB.QueryRowContext(ctx, query).Scan(&applied); err != nil {
+		t.Fatalf("count applied rows: %v", err)
+	}
+	if applied != 2 {
+		t.Fatalf("expected the snapshot and one migration to be applied, got %d", applied)
+	}
+}
+
+func TestRunSquashSnapshotReproducesTheSchema(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	suffix := time.Now().UnixNano()
+	original := fmt.Sprintf("tt_squash_original_%d", suffix)
+	replayed := fmt.Sprintf("tt_squash_replayed_%d", suffix)
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		for _, schema := range []string{original, replayed} {
+			_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+		}
+	})
+
+	migrationsDir := t.TempDir()
+	write := func(name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+	write("20230101010101_create_types.sql", strings.Join([]string{
+		"CREATE TYPE mood AS ENUM ('sad', 'ok', 'happy');",
+		"CREATE DOMAIN positive_int AS INT NOT NULL CHECK (VALUE > 0);",
+		`CREATE TYPE dimensions AS (width positive_int, height INT, label TEXT COLLATE "C");`,
+	}, "\n")+"\n")
+	write("20230101010202_create_widgets.sql", strings.Join([]string{
+		"CREATE TABLE widgets (id INT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, mood mood NOT NULL DEFAULT 'ok', size dimensions, stock positive_int, updated_at TIMESTAMPTZ);",
+		"CREATE FUNCTION touch_updated_at() RETURNS trigger LANGUAGE plpgsql AS $$",
+		"BEGIN",
+		"  NEW.updated_at := now();",
+		"  RETURN NEW;",
+		"END;",
+		"$$;",
+		"CREATE TRIGGER widgets_touch BEFORE UPDATE ON widgets FOR EACH ROW EXECUTE FUNCTION touch_updated_at();",
+	}, "\n")+"\n")
+	write("20230101010303_create_parts.sql", strings.Join([]string{
+		"CREATE TABLE parts (id INT PRIMARY KEY, widget_id INT REFERENCES widgets (id), line_no SERIAL);",
+		// Functions that need a table to exist, in their signature or in a
+		// SQL-standard body, sort before it by name.
+		"CREATE FUNCTION a_widget_parts(w widgets) RETURNS SETOF parts LANGUAGE sql AS $$ SELECT * FROM parts WHERE widget_id = w.id $$;",
+		"CREATE FUNCTION a_part_count() RETURNS BIGINT LANGUAGE sql",
+		"BEGIN ATOMIC",
+		"  SELECT count(*) FROM parts;",
+		"END;",
+		"CREATE VIEW happy_widgets AS SELECT id FROM widgets WHERE mood = 'happy';",
+		"ALTER DOMAIN positive_int ADD CONSTRAINT positive_int_small CHECK (VALUE < 1000000);",
+	}, "\n")+"\n")
+
+	originalCfg := config.Config{DatabaseURL: dsn, MigrationsDir: migrationsDir, TargetSchema: original, DumpFile: filepath.Join(t.TempDir(), "original.sql")}
+	replayedCfg := config.Config{DatabaseURL: dsn, MigrationsDir: migrationsDir, TargetSchema: replayed, DumpFile: filepath.Join(t.TempDir(), "replayed.sql")}
+
+	fingerprint := func(schema string) string {
+		t.Helper()
+		migrator, err := migrate.New(migrate.Options{DB: adminDB, FS: fstest.MapFS{}, TargetSchema: schema})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		result, err := migrator.CheckSchema(ctx)
+		if err != nil {
+			t.Fatalf("CheckSchema(%s): %v", schema, err)
+		}
+		return result.Fingerprint
+	}
+
+	if err := app.RunUp(ctx, originalCfg, nil); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+	if err := app.RunDump(ctx, originalCfg, nil); err != nil {
+		t.Fatalf("RunDump of the original schema: %v", err)
+	}
+
+	if err := app.RunSquash(ctx, originalCfg, "20230101010303", nil); err != nil {
+		t.Fatalf("RunSquash: %v", err)
+	}
+	snapshot, err := os.ReadFile(filepath.Join(migrationsDir, "20230101010303_squashed.sql"))
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	for _, want := range []string{"CREATE TYPE mood AS ENUM", "CREATE DOMAIN positive_int", "CREATE TYPE dimensions AS (", "CREATE TRIGGER widgets_touch", "FUNCTION a_widget_parts(w widgets)", "ALTER SEQUENCE parts_line_no_seq OWNED BY parts.line_no;"} {
+		if !strings.Contains(string(snapshot), want) {
+			t.Fatalf("expected snapshot to contain %q, got:\n%s", want, snapshot)
+		}
+	}
+
+	// Replaying the snapshot into an empty schema must rebuild exactly what
+	// the original files built.
+	if err := app.RunUp(ctx, replayedCfg, nil); err != nil {
+		t.Fatalf("RunUp of the snapshot: %v", err)
+	}
+	if err := app.RunDump(ctx, replayedCfg, nil); err != nil {
+		t.Fatalf("RunDump of the replayed schema: %v", err)
+	}
+	if got, want := fingerprint(replayed), fingerprint(original); got != want {
+		t.Fatalf("expected the replayed fingerprint %s to match the original %s", got, want)
+	}
+	originalDump, err := os.ReadFile(originalCfg.DumpFile)
+	if err != nil {
+		t.Fatalf("read original dump: %v", err)
+	}
+	replayedDump, err := os.ReadFile(replayedCfg.DumpFile)
+	if err != nil {
+		t.Fatalf("read replayed dump: %v", err)
+	}
+	if string(replayedDump) != string(originalDump) {
+		t.Fatalf("expected the replayed schema to dump like the original\noriginal:\n%s\nreplayed:\n%s", originalDump, replayedDump)
+	}
+
+	// Data and objects the dump cannot express stop a squash.
+	write("20230101010404_seed_widgets.sql", "INSERT INTO widgets (mood, stock) VALUES ('happy', 3);\n")
+	write("20230101010505_create_span.sql", "CREATE TYPE span AS RANGE (subtype = int);\n")
+	if err := app.RunUp(ctx, originalCfg, nil); err != nil {
+		t.Fatalf("RunUp of the later migrations: %v", err)
+	}
+	err = app.RunSquash(ctx, originalCfg, "20230101010505", nil)
+	if err == nil {
+		t.Fatalf("expected squash to refuse migrations it cannot reproduce")
+	}
+	for _, want := range []string{"rows in widgets", "range type span"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in the refusal, got %v", want, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(migrationsDir, "20230101010505_squashed.sql")); !os.IsNotExist(err) {
+		t.Fatalf("expected no snapshot to be written, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+const (
+	// StatusExitPending is returned by `tinytoe status` when migrations are pending.
+	StatusExitPending = 1
+	// StatusExitDrift is returned by `tinytoe status` when drift is detected or a
+	// check (configuration, connectivity) fails.
+	StatusExitDrift = 2
+)
+
+// RunStatus lists every migration file alongside its applied state without
+// modifying the database. It returns an *ExitError carrying StatusExitPending
+// when migrations are pending and StatusExitDrift on drift or failed checks.
+func RunStatus(ctx context.Context, cfg config.Config, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	printer := newPrinter(cfg, stdout)
+
+	if err := requireMigrationsDir(cfg.MigrationsDir); err != nil {
+		return withExitCode(StatusExitDrift, err)
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return withExitCode(StatusExitDrift, err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return withExitCode(StatusExitDrift, err)
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return withExitCode(StatusExitDrift, err)
+	}
+
+	entries := make([]statusEntry, 0, len(status.Migrations))
+	for _, migration := range status.Migrations {
+		entries = append(entries, newStatusEntry(migration))
+	}
+	if len(entries) > 0 {
+		rows := make([]ui.Row, 0, len(entries))
+		for _, entry := range entries {
+			rows = append(rows, entry.row())
+		}
+		printer.PrintRows(rows)
+		printer.PrintBreak()
+	}
+
+	result := "database up to date"
+	switch {
+	case status.Drift != nil:
+		result = "drift detected"
+	case status.Pending > 0:
+		result = fmt.Sprintf("%d pending migration(s)", status.Pending)
+	}
+
+	details := []ui.Detail{
+		{Label: "Target Schema", Value: cfg.TargetSchema},
+		{Label: "Migrations Directory", Value: cfg.MigrationsDir},
+		{Label: "Applied", Value: fmt.Sprintf("%d migration(s)", status.Applied)},
+		{Label: "Pending", Value: fmt.Sprintf("%d migration(s)", status.Pending)},
+	}
+	if !status.Initialized {
+		details = append(details, ui.Detail{Label: "Migrations Table", Value: "not initialized; run `toe init` or `toe up`"})
+	}
+
+	printer.PrintDelight(ui.Delight{
+		Command: "status",
+		Result:  result,
+		Details: details,
+		Data: map[string]interface{}{
+			"target_schema": cfg.TargetSchema,
+			"migrations":    entries,
+			"applied":       status.Applied,
+			"pending":       status.Pending,
+			"drift":         status.Drift != nil,
+		},
+	})
+
+	if status.Drift != nil {
+		return withExitCode(StatusExitDrift, status.Drift)
+	}
+	if status.Pending > 0 {
+		return withExitCode(StatusExitPending, fmt.Errorf("database has %d pending migration(s)", status.Pending))
+	}
+	return nil
+}
+
+// statusEntry describes the state of a single migration.
+type statusEntry struct {
+	Filename  string `json:"filename"`
+	Version   string `json:"version"`
+	State     string `json:"state"`
+	AppliedAt string `json:"applied_at,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+func newStatusEntry(migration migrate.MigrationStatus) statusEntry {
+	entry := statusEntry{
+		Filename: migration.Filename,
+		Version:  migration.Version,
+		State:    migration.State,
+		Detail:   migration.Detail,
+	}
+	if !migration.AppliedAt.IsZero() {
+		entry.AppliedAt = formatAppliedAt(migration.AppliedAt)
+	}
+	return entry
+}
+
+func (e statusEntry) row() ui.Row {
+	switch e.State {
+	case migrate.StateApplied, migrate.StateBaselined, migrate.StateSquashed:
+		if e.Detail != "" {
+			return ui.Row{Columns: []string{e.Filename, e.State + " " + e.AppliedAt + " (" + e.Detail + ")"}}
+		}
+		return ui.Row{Columns: []string{e.Filename, e.State + " " + e.AppliedAt}}
+	case migrate.StateDrift:
+		return ui.Row{Columns: []string{e.Filename, "drift (" + e.Detail + ")"}, Kind: ui.DetailWarning}
+	case migrate.StatePending:
+		if e.Detail != "" {
+			return ui.Row{Columns: []string{e.Filename, "pending (" + e.Detail + ")"}}
+		}
+		return ui.Row{Columns: []string{e.Filename, e.State}}
+	default:
+		return ui.Row{Columns: []string{e.Filename, e.State}}
+	}
+}
+
+func formatAppliedAt(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,125 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunStatusReportsAppliedPendingAndDrift(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_status_%d", time.Now().UnixNano())
+	ctx := context.Background()
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+
+	first := filepath.Join(migrationsDir, "20230101010101_create_widgets.sql")
+	if err := os.WriteFile(first, []byte("CREATE TABLE widgets (id INT PRIMARY KEY);\n"), 0o644); err != nil {
+		t.Fatalf("write first migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := app.RunStatus(ctx, cfg, &out); err != nil {
+		t.Fatalf("RunStatus when current: %v", err)
+	}
+	if !strings.Contains(out.String(), fmt.Sprintf("tinytoe status %s database up to date", ui.Arrow)) {
+		t.Fatalf("expected up-to-date message, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "20230101010101_create_widgets.sql  applied ") {
+		t.Fatalf("expected applied row, got %q", out.String())
+	}
+
+	second := filepath.Join(migrationsDir, "20230101010202_seed_widgets.sql")
+	if err := os.WriteFile(second, []byte("INSERT INTO widgets VALUES (1);\n"), 0o644); err != nil {
+		t.Fatalf("write second migration: %v", err)
+	}
+
+	out.Reset()
+	err = app.RunStatus(ctx, cfg, &out)
+	assertExitCode(t, err, app.StatusExitPending)
+	if !strings.Contains(out.String(), "20230101010202_seed_widgets.sql    pending") {
+		t.Fatalf("expected pending row, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "1 pending migration(s)") {
+		t.Fatalf("expected pending summary, got %q", out.String())
+	}
+
+	if err := os.Rename(first, filepath.Join(migrationsDir, "20230101010101_create_gadgets.sql")); err != nil {
+		t.Fatalf("rename migration: %v", err)
+	}
+
+	out.Reset()
+	err = app.RunStatus(ctx, cfg, &out)
+	assertExitCode(t, err, app.StatusExitDrift)
+	if !strings.Contains(err.Error(), "detected drift") {
+		t.Fatalf("expected drift error, got %v", err)
+	}
+	if !strings.Contains(out.String(), ui.WarningEmoji+" 20230101010101_create_gadgets.sql") {
+		t.Fatalf("expected highlighted drift row, got %q", out.String())
+	}
+}
+
+func TestRunStatusFailsCheckWhenMigrationsDirMissing(t *testing.T) {
+	cfg := config.Config{
+		MigrationsDir: filepath.Join(t.TempDir(), "missing"),
+		TargetSchema:  "public",
+	}
+
+	err := app.RunStatus(context.Background(), cfg, nil)
+	assertExitCode(t, err, app.StatusExitDrift)
+	if !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected missing directory error, got %v", err)
+	}
+}
+
+func assertExitCode(t *testing.T, err error, want int) {
+	t.Helper()
+	var exitErr *app.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected *app.ExitError with code %d, got %v", want, err)
+	}
+	if exitErr.Code != want {
+		t.Fatalf("expected exit code %d, got %d (%v)", want, exitErr.Code, err)
+	}
+}
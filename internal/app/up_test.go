@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,7 @@ import (
 	"tinytoe/internal/app"
 	"tinytoe/internal/config"
 	"tinytoe/internal/ui"
+	"tinytoe/migrate"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
@@ -251,3 +253,652 @@ func TestRunUpDetectsRenamedMigrationFile(t *testing.T) {
 		t.Fatalf("expected drift error, got %v", err)
 	}
 }
+
+func TestRunUpDetectsModifiedMigrationFile(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_modified_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+
+	migrationFile := filepath.Join(migrationsDir, "20230101010101_create_table.sql")
+	if err := os.WriteFile(migrationFile, []byte("CREATE TABLE demo (id INT PRIMARY KEY);\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("initial RunUp: %v", err)
+	}
+
+	if err := os.WriteFile(migrationFile, []byte("CREATE TABLE demo (id BIGINT PRIMARY KEY);\n"), 0o644); err != nil {
+		t.Fatalf("modify migration: %v", err)
+	}
+
+	err = app.RunUp(ctx, cfg, nil)
+	if err == nil {
+		t.Fatalf("expected error when applied migration is modified")
+	}
+	if !strings.Contains(err.Error(), "was modified after it was applied") {
+		t.Fatalf("expected modified drift error, got %v", err)
+	}
+}
+
+func TestRunUpUpgradesLegacyMigrationsTable(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_legacy_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	if _, err := adminDB.ExecContext(ctx, fmt.Sprintf("CREATE SCHEMA %s", quoteIdent(schema))); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	legacyDDL := fmt.Sprintf(`
+CREATE TABLE %s (
+	version VARCHAR(255) PRIMARY KEY,
+	filename VARCHAR(1024) NOT NULL,
+	applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`, qualify(schema, "tinytoe_migrations"))
+	if _, err := adminDB.ExecContext(ctx, legacyDDL); err != nil {
+		t.Fatalf("create legacy migrations table: %v", err)
+	}
+	legacyInsert := fmt.Sprintf(`INSERT INTO %s (version, filename) VALUES ('20230101010101', '20230101010101_create_table.sql')`, qualify(schema, "tinytoe_migrations"))
+	if _, err := adminDB.ExecContext(ctx, legacyInsert); err != nil {
+		t.Fatalf("insert legacy row: %v", err)
+	}
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+	migrationFile := filepath.Join(migrationsDir, "20230101010101_create_table.sql")
+	if err := os.WriteFile(migrationFile, []byte("CREATE TABLE demo (id INT PRIMARY KEY);\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp against legacy table: %v", err)
+	}
+
+	var checksum sql.NullString
+	query := fmt.Sprintf(`SELECT checksum FROM %s WHERE version = '20230101010101'`, qualify(schema, "tinytoe_migrations"))
+	if err := adminDB.QueryRowContext(ctx, query).Scan(&checksum); err != nil {
+		t.Fatalf("query checksum: %v", err)
+	}
+	if !checksum.Valid || len(checksum.String) != 64 {
+		t.Fatalf("expected backfilled SHA-256 checksum, got %+v", checksum)
+	}
+}
+
+func TestRunUpDryRunPrintsPlanWithoutApplying(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_dry_run_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+
+	body := "CREATE TABLE demo (id INT PRIMARY KEY);\n"
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_table.sql"), []byte(body), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	var out bytes.Buffer
+	if err := app.RunUpWithOptions(ctx, cfg, migrate.UpOptions{DryRun: true}, &out); err != nil {
+		t.Fatalf("RunUpWithOptions dry run: %v", err)
+	}
+
+	output := out.String()
+	if !strings.Contains(output, "-- 20230101010101_create_table.sql\n"+body) {
+		t.Fatalf("expected plan to include migration body, got %q", output)
+	}
+	if !strings.Contains(output, fmt.Sprintf("tinytoe up %s dry run complete; no changes made", ui.Arrow)) {
+		t.Fatalf("expected dry run summary, got %q", output)
+	}
+
+	var count int
+	if err := adminDB.QueryRowContext(ctx, `
+SELECT COUNT(*) FROM information_schema.schemata
+WHERE schema_name = $1
+`, schema).Scan(&count); err != nil {
+		t.Fatalf("query schema existence: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected dry run to leave schema %s uncreated", schema)
+	}
+}
+
+func TestRunUpAppliesNoTransactionMigration(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_no_tx_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_widgets.sql"), []byte("CREATE TABLE widgets (id INT PRIMARY KEY, name TEXT);\n"), 0o644); err != nil {
+		t.Fatalf("write first migration: %v", err)
+	}
+	concurrently := strings.Join([]string{
+		"-- Tiny Toe Migration",
+		"-- tinytoe:no-transaction",
+		"",
+		"CREATE INDEX CONCURRENTLY widgets_name_idx ON widgets (name);",
+		"COMMENT ON INDEX widgets_name_idx IS 'built; concurrently';",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010202_index_widgets.sql"), []byte(concurrently+"\n"), 0o644); err != nil {
+		t.Fatalf("write second migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+
+	var indexExists bool
+	if err := adminDB.QueryRowContext(ctx, `
+SELECT EXISTS (
+	SELECT 1 FROM pg_indexes
+	WHERE schemaname = $1 AND indexname = 'widgets_name_idx'
+)`, schema).Scan(&indexExists); err != nil {
+		t.Fatalf("query index: %v", err)
+	}
+	if !indexExists {
+		t.Fatalf("expected widgets_name_idx to exist")
+	}
+
+	failing := "-- tinytoe:no-transaction\nCREATE TABLE gadgets (id INT);\nSELECT * FROM missing_table;\n"
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010303_broken.sql"), []byte(failing), 0o644); err != nil {
+		t.Fatalf("write failing migration: %v", err)
+	}
+
+	err = app.RunUp(ctx, cfg, nil)
+	if err == nil {
+		t.Fatalf("expected failure from broken no-transaction migration")
+	}
+	if !strings.Contains(err.Error(), "manual attention") || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected manual attention error naming the failing line, got %v", err)
+	}
+}
+
+func TestRunUpRejectsUnknownDirective(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_directive_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_typo.sql"), []byte("-- tinytoe:no-transacton\nSELECT 1;\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	err = app.RunUp(ctx, cfg, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown directive tinytoe:no-transacton") {
+		t.Fatalf("expected unknown directive error, got %v", err)
+	}
+}
+
+func TestRunUpRecordsExecutionMetadata(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+	t.Setenv("USER", "tinytoe-test")
+
+	schema := fmt.Sprintf("tt_up_metadata_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_table.sql"), []byte("CREATE TABLE demo (id INT PRIMARY KEY);\n"), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+
+	var (
+		executionMS    sql.NullInt64
+		appliedBy      sql.NullString
+		tinytoeVersion sql.NullString
+	)
+	query := fmt.Sprintf(`SELECT execution_ms, applied_by, tinytoe_version FROM %s`, qualify(schema, "tinytoe_migrations"))
+	if err := adminDB.QueryRowContext(ctx, query).Scan(&executionMS, &appliedBy, &tinytoeVersion); err != nil {
+		t.Fatalf("query metadata: %v", err)
+	}
+	if !executionMS.Valid || executionMS.Int64 < 0 {
+		t.Fatalf("expected execution_ms to be recorded, got %+v", executionMS)
+	}
+	if !strings.HasPrefix(appliedBy.String, "tinytoe-test") {
+		t.Fatalf("expected applied_by to start with tinytoe-test, got %q", appliedBy.String)
+	}
+	if tinytoeVersion.String == "" {
+		t.Fatalf("expected tinytoe_version to be recorded")
+	}
+}
+
+func TestRunUpHonorsTargetAndSteps(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_target_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+	for i, name := range []string{"20230101010101_one.sql", "20230101010202_two.sql", "20230101010303_three.sql", "20230101010404_four.sql"} {
+		body := fmt.Sprintf("CREATE TABLE t%d (id INT);\n", i+1)
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	countApplied := func() int {
+		t.Helper()
+		var count int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s", qualify(schema, "tinytoe_migrations"))
+		if err := adminDB.QueryRowContext(ctx, query).Scan(&count); err != nil {
+			t.Fatalf("count migrations: %v", err)
+		}
+		return count
+	}
+
+	if err := app.RunUpWithOptions(ctx, cfg, migrate.UpOptions{Target: "20230101010999"}, nil); err == nil || !strings.Contains(err.Error(), "does not match any migration") {
+		t.Fatalf("expected unknown target error, got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := app.RunUpWithOptions(ctx, cfg, migrate.UpOptions{Target: "20230101010202"}, &out); err != nil {
+		t.Fatalf("RunUpWithOptions --to: %v", err)
+	}
+	if got := countApplied(); got != 2 {
+		t.Fatalf("expected 2 applied migrations after --to, got %d", got)
+	}
+	if !strings.Contains(out.String(), "Remaining: 2 pending migration(s)") {
+		t.Fatalf("expected remaining detail, got %q", out.String())
+	}
+
+	if err := app.RunUpWithOptions(ctx, cfg, migrate.UpOptions{Target: "20230101010101"}, nil); err == nil || !strings.Contains(err.Error(), "behind the applied state") {
+		t.Fatalf("expected behind-target error, got %v", err)
+	}
+
+	if err := app.RunUpWithOptions(ctx, cfg, migrate.UpOptions{Steps: 1}, nil); err != nil {
+		t.Fatalf("RunUpWithOptions --step: %v", err)
+	}
+	if got := countApplied(); got != 3 {
+		t.Fatalf("expected 3 applied migrations after --step 1, got %d", got)
+	}
+}
+
+func TestRunUpAppliesServerTimeouts(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_timeouts_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	tempDir := t.TempDir()
+	migrationsDir := filepath.Join(tempDir, "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_create_widgets.sql"), []byte("CREATE TABLE widgets (id INT PRIMARY KEY);\n"), 0o644); err != nil {
+		t.Fatalf("write first migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("initial RunUp: %v", err)
+	}
+
+	slow := filepath.Join(migrationsDir, "20230101010202_slow.sql")
+	if err := os.WriteFile(slow, []byte("SELECT pg_sleep(2);\n"), 0o644); err != nil {
+		t.Fatalf("write slow migration: %v", err)
+	}
+
+	limited := cfg
+	limited.StatementTimeout = 100 * time.Millisecond
+	err = app.RunUp(ctx, limited, nil)
+	if err == nil || !strings.Contains(err.Error(), "statement timeout") {
+		t.Fatalf("expected statement timeout error, got %v", err)
+	}
+	if err := os.Remove(slow); err != nil {
+		t.Fatalf("remove slow migration: %v", err)
+	}
+
+	holder, err := adminDB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin lock holder: %v", err)
+	}
+	defer holder.Rollback()
+	if _, err := holder.ExecContext(ctx, fmt.Sprintf("LOCK TABLE %s IN ACCESS EXCLUSIVE MODE", qualify(schema, "widgets"))); err != nil {
+		t.Fatalf("lock widgets: %v", err)
+	}
+
+	alter := "-- tinytoe:lock-timeout=200ms\nALTER TABLE widgets ADD COLUMN name TEXT;\n"
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010303_alter_widgets.sql"), []byte(alter), 0o644); err != nil {
+		t.Fatalf("write alter migration: %v", err)
+	}
+
+	err = app.RunUp(ctx, cfg, nil)
+	if err == nil || !strings.Contains(err.Error(), "lock timeout") {
+		t.Fatalf("expected lock timeout error from directive, got %v", err)
+	}
+}
+
+func TestRunUpReportsInterruptedMigration(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_interrupt_%d", time.Now().UnixNano())
+
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	migrationsDir := filepath.Join(t.TempDir(), "migrations")
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		t.Fatalf("mkdir migrations dir: %v", err)
+	}
+	slow := "CREATE TABLE widgets (id INT);\nSELECT pg_sleep(30);\n"
+	if err := os.WriteFile(filepath.Join(migrationsDir, "20230101010101_slow.sql"), []byte(slow), 0o644); err != nil {
+		t.Fatalf("write migration: %v", err)
+	}
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Second, cancel)
+
+	started := time.Now()
+	err = app.RunUp(ctx, cfg, nil)
+	var interrupted *migrate.InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("expected InterruptedError, got %v", err)
+	}
+	if interrupted.Migration.Filename != "20230101010101_slow.sql" || !interrupted.RolledBack {
+		t.Fatalf("expected rolled back slow migration, got %+v", interrupted)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("expected the running statement to be cancelled promptly, took %s", elapsed)
+	}
+
+	var tables int
+	if err := adminDB.QueryRowContext(context.Background(), `
+SELECT COUNT(*) FROM information_schema.tables
+WHERE table_schema = $1 AND table_name = 'widgets'`, schema).Scan(&tables); err != nil {
+		t.Fatalf("query widgets table: %v", err)
+	}
+	if tables != 0 {
+		t.Fatalf("expected interrupted migration to be rolled back")
+	}
+}
+
+func TestRunUpAppliesOutOfOrderMigrationsWhenAllowed(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_up_out_of_order_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	t.Cleanup(func() {
+		_, _ = adminDB.ExecContext(context.Background(), fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema)))
+	})
+
+	migrationsDir := t.TempDir()
+	writeMigration := func(name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+	writeMigration("20230101010101_one.sql", "CREATE TABLE t1 (id INT);\n")
+	writeMigration("20230101010303_three.sql", "CREATE TABLE t3 (id INT);\n")
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+	}
+	if err := app.RunUp(ctx, cfg, nil); err != nil {
+		t.Fatalf("RunUp: %v", err)
+	}
+
+	// A branch merged after staging moved on brings an older migration.
+	writeMigration("20230101010202_two.sql", "CREATE TABLE t2 (id INT);\n")
+
+	if err := app.RunUp(ctx, cfg, nil); err == nil || !strings.Contains(err.Error(), "--allow-out-of-order") {
+		t.Fatalf("expected drift mentioning --allow-out-of-order, got %v", err)
+	}
+
+	cfg.AllowOutOfOrder = true
+	var out bytes.Buffer
+	if err := app.RunUpWithOptions(ctx, cfg, migrate.UpOptions{DryRun: true}, &out); err != nil {
+		t.Fatalf("RunUpWithOptions dry run: %v", err)
+	}
+	if !strings.Contains(out.String(), "20230101010202_two.sql (out of order)") {
+		t.Fatalf("expected the plan to flag the out-of-order migration, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := app.RunUp(ctx, cfg, &out); err != nil {
+		t.Fatalf("RunUp out of order: %v", err)
+	}
+	if !strings.Contains(out.String(), "Applying 20230101010202_two.sql out of order; 20230101010303_three.sql is already applied") {
+		t.Fatalf("expected an out-of-order warning, got %q", out.String())
+	}
+
+	query := fmt.Sprintf("SELECT version FROM %s ORDER BY applied_order", qualify(schema, "tinytoe_migrations"))
+	rows, err := adminDB.QueryContext(ctx, query)
+	if err != nil {
+		t.Fatalf("query applied order: %v", err)
+	}
+	defer rows.Close()
+	var order []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			t.Fatalf("scan applied order: %v", err)
+		}
+		order = append(order, version)
+	}
+	if got := strings.Join(order, ","); got != "20230101010101,20230101010303,20230101010202" {
+		t.Fatalf("expected the actual application order to be recorded, got %s", got)
+	}
+
+	out.Reset()
+	if err := app.RunStatus(ctx, cfg, &out); err != nil {
+		t.Fatalf("RunStatus: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "(out of order)") {
+		t.Fatalf("expected status to flag the out-of-order migration, got %q", out.String())
+	}
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"tinytoe/internal/config"
+	"tinytoe/internal/ui"
+	"tinytoe/migrate"
+)
+
+// RunVerify replays every migration in a throwaway schema to prove the chain
+// applies from scratch. The schema is always dropped afterwards.
+func RunVerify(ctx context.Context, cfg config.Config, stdout io.Writer) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	if err := requireMigrationsDir(cfg.MigrationsDir); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	printer := newPrinter(cfg, stdout)
+	migrator, err := migrate.New(migratorOptions(cfg, db, printer))
+	if err != nil {
+		return err
+	}
+
+	result, err := migrator.Verify(ctx)
+	if err != nil {
+		if result != nil && result.Failed != nil {
+			printer.PrintWarning(fmt.Sprintf("%s failed to apply after %d migration(s) replayed cleanly in temporary schema %q (dropped)", result.Failed.Filename, len(result.Applied), result.Schema))
+		}
+		return fmt.Errorf("verify migrations: %w", err)
+	}
+
+	printer.PrintDelight(ui.Delight{
+		Command: "verify",
+		Result:  "migrations replay cleanly",
+		Details: []ui.Detail{
+			{Label: "Replayed", Value: fmt.Sprintf("%d migration(s)", len(result.Applied))},
+			{Label: "Temporary Schema", Value: fmt.Sprintf("%s (dropped)", result.Schema)},
+			{Label: "Migrations Directory", Value: cfg.MigrationsDir},
+		},
+		Data: map[string]interface{}{
+			"schema":  result.Schema,
+			"applied": result.Applied,
+		},
+	})
+	return nil
+}
@@ -0,0 +1,90 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"tinytoe/internal/app"
+	"tinytoe/internal/config"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestRunVerifyReplaysInTemporarySchemaAndDropsIt(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	adminDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open admin database: %v", err)
+	}
+	defer adminDB.Close()
+
+	migrationsDir := t.TempDir()
+	write := func(name, body string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+	write("20230101010101_create_widgets.sql", "CREATE TABLE widgets (id INT PRIMARY KEY);\n")
+	// A SQL-standard body holds semicolons that must reach the server whole.
+	write("20230101010150_widget_count.sql", "CREATE FUNCTION widget_count() RETURNS BIGINT LANGUAGE sql\nBEGIN ATOMIC\n  SELECT 1;\n  SELECT count(*) FROM widgets;\nEND;\n")
+	write("20230101010202_seed_widgets.sql", "INSERT INTO widgets (id) VALUES (1);\n")
+
+	cfg := config.Config{
+		DatabaseURL:   dsn,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  "public",
+	}
+
+	schemaPattern := regexp.MustCompile(`tinytoe_verify_\d+`)
+	assertDropped := func(output string) {
+		t.Helper()
+		schema := schemaPattern.FindString(output)
+		if schema == "" {
+			t.Fatalf("expected temporary schema in output, got %q", output)
+		}
+		var count int
+		if err := adminDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM pg_namespace WHERE nspname = $1`, schema).Scan(&count); err != nil {
+			t.Fatalf("query schema: %v", err)
+		}
+		if count != 0 {
+			t.Fatalf("expected %s to be dropped", schema)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := app.RunVerify(ctx, cfg, &stdout); err != nil {
+		t.Fatalf("RunVerify: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "migrations replay cleanly") {
+		t.Fatalf("expected success output, got %q", stdout.String())
+	}
+	assertDropped(stdout.String())
+
+	// A duplicate key carries no error position, yet the statement is named.
+	write("20230101010303_broken.sql", "-- Tiny Toe Migration\nINSERT INTO widgets (id) VALUES (2);\nINSERT INTO widgets (id) VALUES (1);\n")
+
+	stdout.Reset()
+	err = app.RunVerify(ctx, cfg, &stdout)
+	if err == nil {
+		t.Fatalf("expected verify to fail on the broken migration")
+	}
+	if !strings.Contains(err.Error(), "20230101010303_broken.sql") || !strings.Contains(err.Error(), "line 3 (INSERT INTO widgets (id) VALUES (1))") {
+		t.Fatalf("expected failing file and statement line, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "20230101010303_broken.sql failed to apply after 3 migration(s)") {
+		t.Fatalf("expected failure report, got %q", stdout.String())
+	}
+	assertDropped(stdout.String())
+}
@@ -6,8 +6,21 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
+// DefaultMigrationTimeout bounds how long a single migration may run on the
+// client side when TINYTOE_MIGRATION_TIMEOUT is unset.
+const DefaultMigrationTimeout = 2 * time.Minute
+
+// DefaultLockWaitTimeout bounds how long `tinytoe up` waits for another runner
+// to release the migration lock when TINYTOE_LOCK_WAIT_TIMEOUT is unset.
+const DefaultLockWaitTimeout = time.Minute
+
+// DefaultConnectTimeout is how long --wait keeps retrying the first database
+// connection when TINYTOE_CONNECT_TIMEOUT does not say otherwise.
+const DefaultConnectTimeout = time.Minute
+
 // Config captures the minimal configuration needed for Tiny Toe operations.
 type Config struct {
 	DatabaseURL    string
@@ -15,6 +28,43 @@ type Config struct {
 	Force          bool
 	NonInteractive bool
 	TargetSchema   string
+	// ConnectTimeout is how long to keep retrying the first database
+	// connection, with exponential backoff. Zero tries once.
+	ConnectTimeout time.Duration
+	// LockWaitTimeout limits how long to wait for the migration advisory lock.
+	// Zero waits indefinitely.
+	LockWaitTimeout time.Duration
+	// LockTimeout sets PostgreSQL's lock_timeout for each migration. Zero
+	// leaves the server default in place.
+	LockTimeout time.Duration
+	// StatementTimeout sets PostgreSQL's statement_timeout for each migration.
+	// Zero leaves the server default in place.
+	StatementTimeout time.Duration
+	// MigrationTimeout bounds each migration on the client side. Zero disables
+	// the limit for long-running data migrations.
+	MigrationTimeout time.Duration
+	// Output selects the output format: "text" (default) or "json".
+	Output string
+	// Environment names the config file environment in use, if any.
+	Environment string
+	// Protected marks the environment as protected in the config file.
+	Protected bool
+	// LintDisabled names `tinytoe lint` rules to skip.
+	LintDisabled []string
+	// DumpFile is where `tinytoe dump` writes the schema snapshot. Empty means
+	// schema.sql in the migrations directory.
+	DumpFile string
+	// DumpOnUp refreshes the schema snapshot after every successful up.
+	DumpOnUp bool
+	// AllowOutOfOrder applies pending migrations older than the latest applied
+	// one instead of reporting them as drift.
+	AllowOutOfOrder bool
+	// AllowProtected names the database that dropall and reset may drop even
+	// though it is protected. It is only set from the command line.
+	AllowProtected string
+	// AllowExisting lets baseline run on a database that already records
+	// migrations. Unlike Force, it is only set from the command line.
+	AllowExisting bool
 }
 
 // LoadOptions tune how LoadWithOptions behaves for individual commands.
@@ -23,6 +73,18 @@ type LoadOptions struct {
 	RequireDatabase *bool
 	// ForceOverride allows callers to bypass environment detection for the force flag.
 	ForceOverride *bool
+	// OutputOverride allows callers to replace TINYTOE_OUTPUT (e.g. from --output).
+	OutputOverride *string
+	// EnvOverride allows callers to replace TINYTOE_ENV (e.g. from --env).
+	EnvOverride *string
+	// ConfigPath replaces TINYTOE_CONFIG and the default tinytoe.toml.
+	ConfigPath string
+	// Wait enables connection retries (e.g. from a bare --wait), using
+	// DefaultConnectTimeout when no connect timeout is configured.
+	Wait bool
+	// ConnectTimeoutOverride allows callers to replace TINYTOE_CONNECT_TIMEOUT
+	// (e.g. from --wait=<duration>).
+	ConnectTimeoutOverride *time.Duration
 }
 
 // Load reads configuration from environment variables with the default options,
@@ -31,14 +93,77 @@ func Load() (Config, error) {
 	return LoadWithOptions(LoadOptions{})
 }
 
-// LoadWithOptions reads configuration from environment variables, applying
-// defaults and validation according to the supplied options. The caller is
-// expected to preload any additional configuration sources (e.g. .env files).
+// LoadWithOptions reads configuration from the project config file and
+// environment variables, applying defaults and validation according to the
+// supplied options. Precedence from lowest to highest is defaults, the config
+// file (top-level settings, then the selected environment), environment
+// variables, then the overrides in opts. The one exception is the database: an
+// environment selected through opts.EnvOverride that sets database_url or
+// database_url_env takes precedence over DATABASE_URL. The caller is expected to preload any
+// additional configuration sources (e.g. .env files) into the environment.
 func LoadWithOptions(opts LoadOptions) (Config, error) {
-	cfg := Config{
-		DatabaseURL:   strings.TrimSpace(os.Getenv("DATABASE_URL")),
-		MigrationsDir: strings.TrimSpace(os.Getenv("TINYTOE_MIGRATIONS_DIR")),
-		TargetSchema:  strings.TrimSpace(os.Getenv("TINYTOE_TARGET_SCHEMA")),
+	file, settings, envName, err := loadFileSettings(opts)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// lookup returns the environment variable when set, falling back to the
+	// config file, along with a name for error messages.
+	lookup := func(envVar, key string) (string, string) {
+		if value := strings.TrimSpace(os.Getenv(envVar)); value != "" {
+			return value, envVar
+		}
+		if setting, ok := settings[key]; ok {
+			return strings.TrimSpace(setting.value), fmt.Sprintf("%s (%s line %d)", key, file.path, setting.line)
+		}
+		return "", envVar
+	}
+
+	// lookupBool is lookup for booleans, which must be written without quotes
+	// in the config file. An empty envVar reads the config file only.
+	lookupBool := func(envVar, key string) (bool, error) {
+		if envVar != "" {
+			if value := strings.TrimSpace(os.Getenv(envVar)); value != "" {
+				return parseBoolEnv(value, envVar)
+			}
+		}
+		setting, ok := settings[key]
+		if !ok {
+			return false, nil
+		}
+		name := fmt.Sprintf("%s (%s line %d)", key, file.path, setting.line)
+		if setting.quoted {
+			return false, fmt.Errorf("parse %s: expected true or false without quotes", name)
+		}
+		return parseBoolEnv(setting.value, name)
+	}
+
+	cfg := Config{Environment: envName}
+
+	cfg.DatabaseURL, _ = lookup("DATABASE_URL", "database_url")
+	// An environment picked with --env that names its own database beats a
+	// DATABASE_URL exported for local work, which would otherwise point
+	// `--env staging` at the wrong database without a word.
+	if opts.EnvOverride != nil && file.definesDatabaseURL(envName) {
+		cfg.DatabaseURL = strings.TrimSpace(settings["database_url"].value)
+	}
+	var missingDatabaseRef string
+	if ref, ok := settings["database_url_env"]; ok && cfg.DatabaseURL == "" {
+		cfg.DatabaseURL = strings.TrimSpace(os.Getenv(ref.value))
+		if cfg.DatabaseURL == "" {
+			missingDatabaseRef = ref.value
+		}
+	}
+
+	var source string
+	cfg.MigrationsDir, source = lookup("TINYTOE_MIGRATIONS_DIR", "migrations_dir")
+	if cfg.MigrationsDir != "" && source != "TINYTOE_MIGRATIONS_DIR" {
+		cfg.MigrationsDir = file.resolvePath(cfg.MigrationsDir)
+	}
+	cfg.TargetSchema, _ = lookup("TINYTOE_TARGET_SCHEMA", "target_schema")
+	cfg.DumpFile, source = lookup("TINYTOE_DUMP_FILE", "dump_file")
+	if cfg.DumpFile != "" && source != "TINYTOE_DUMP_FILE" {
+		cfg.DumpFile = file.resolvePath(cfg.DumpFile)
 	}
 
 	if cfg.MigrationsDir == "" {
@@ -57,6 +182,17 @@ func LoadWithOptions(opts LoadOptions) (Config, error) {
 		return Config{}, err
 	}
 
+	// protected has no environment variable; it is only read from the file.
+	if cfg.Protected, err = lookupBool("", "protected"); err != nil {
+		return Config{}, err
+	}
+	if cfg.DumpOnUp, err = lookupBool("TINYTOE_DUMP_ON_UP", "dump_on_up"); err != nil {
+		return Config{}, err
+	}
+	if cfg.AllowOutOfOrder, err = lookupBool("TINYTOE_ALLOW_OUT_OF_ORDER", "allow_out_of_order"); err != nil {
+		return Config{}, err
+	}
+
 	force, err := parseBoolEnv(os.Getenv("TINYTOE_FORCE"), "TINYTOE_FORCE")
 	if err != nil {
 		return Config{}, err
@@ -69,9 +205,51 @@ func LoadWithOptions(opts LoadOptions) (Config, error) {
 	}
 	cfg.NonInteractive = nonInteractive
 
+	connectTimeout, name := lookup("TINYTOE_CONNECT_TIMEOUT", "connect_timeout")
+	if cfg.ConnectTimeout, err = parseDurationEnv(connectTimeout, name, 0); err != nil {
+		return Config{}, err
+	}
+	lockWait, name := lookup("TINYTOE_LOCK_WAIT_TIMEOUT", "lock_wait_timeout")
+	if cfg.LockWaitTimeout, err = parseDurationEnv(lockWait, name, DefaultLockWaitTimeout); err != nil {
+		return Config{}, err
+	}
+	lockTimeout, name := lookup("TINYTOE_LOCK_TIMEOUT", "lock_timeout")
+	if cfg.LockTimeout, err = parseDurationEnv(lockTimeout, name, 0); err != nil {
+		return Config{}, err
+	}
+	statementTimeout, name := lookup("TINYTOE_STATEMENT_TIMEOUT", "statement_timeout")
+	if cfg.StatementTimeout, err = parseDurationEnv(statementTimeout, name, 0); err != nil {
+		return Config{}, err
+	}
+	migrationTimeout, name := lookup("TINYTOE_MIGRATION_TIMEOUT", "migration_timeout")
+	if cfg.MigrationTimeout, err = parseDurationEnv(migrationTimeout, name, DefaultMigrationTimeout); err != nil {
+		return Config{}, err
+	}
+
+	lintDisabled, _ := lookup("TINYTOE_LINT_DISABLE", "lint_disable")
+	for _, rule := range strings.Split(lintDisabled, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			cfg.LintDisabled = append(cfg.LintDisabled, rule)
+		}
+	}
+
 	if opts.ForceOverride != nil {
 		cfg.Force = *opts.ForceOverride
 	}
+	if opts.ConnectTimeoutOverride != nil {
+		cfg.ConnectTimeout = *opts.ConnectTimeoutOverride
+	} else if opts.Wait && cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = DefaultConnectTimeout
+	}
+
+	output := os.Getenv("TINYTOE_OUTPUT")
+	if opts.OutputOverride != nil {
+		output = *opts.OutputOverride
+	}
+	cfg.Output, err = ParseOutput(output)
+	if err != nil {
+		return Config{}, err
+	}
 
 	requireDatabase := true
 	if opts.RequireDatabase != nil {
@@ -79,12 +257,64 @@ func LoadWithOptions(opts LoadOptions) (Config, error) {
 	}
 
 	if requireDatabase && cfg.DatabaseURL == "" {
+		if missingDatabaseRef != "" {
+			return Config{}, fmt.Errorf("DATABASE_URL is required; database_url_env in %s refers to %s, which is not set", file.path, missingDatabaseRef)
+		}
 		return Config{}, fmt.Errorf("DATABASE_URL is required")
 	}
 
 	return cfg, nil
 }
 
+// loadFileSettings reads the config file and returns the settings for the
+// selected environment. The file is optional unless a path or environment was
+// asked for explicitly.
+func loadFileSettings(opts LoadOptions) (*configFile, map[string]fileSetting, string, error) {
+	envName := strings.TrimSpace(os.Getenv("TINYTOE_ENV"))
+	if opts.EnvOverride != nil {
+		envName = strings.TrimSpace(*opts.EnvOverride)
+	}
+
+	path := opts.ConfigPath
+	if path == "" {
+		path = strings.TrimSpace(os.Getenv("TINYTOE_CONFIG"))
+	}
+	explicit := path != ""
+	if path == "" {
+		path = DefaultConfigFile
+	}
+
+	file, err := loadConfigFile(path, explicit)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if file == nil {
+		if envName != "" {
+			return nil, nil, "", fmt.Errorf("environment %q was requested but %s does not exist", envName, path)
+		}
+		return &configFile{path: path}, map[string]fileSetting{}, "", nil
+	}
+
+	settings, err := file.environment(envName)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return file, settings, envName, nil
+}
+
+// ParseOutput normalizes an output format name, defaulting to "text".
+func ParseOutput(raw string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	switch value {
+	case "":
+		return "text", nil
+	case "text", "json":
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q; expected text or json", raw)
+	}
+}
+
 func parseBoolEnv(raw, name string) (bool, error) {
 	value := strings.TrimSpace(raw)
 	if value == "" {
@@ -98,6 +328,25 @@ func parseBoolEnv(raw, name string) (bool, error) {
 	return parsed, nil
 }
 
+func parseDurationEnv(raw, name string, fallback time.Duration) (time.Duration, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return fallback, nil
+	}
+	if value == "0" {
+		return 0, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", name, err)
+	}
+	if parsed < 0 {
+		return 0, fmt.Errorf("%s must not be negative, got %s", name, value)
+	}
+	return parsed, nil
+}
+
 func validateTargetSchema(schema string) error {
 	if schema == "" {
 		return fmt.Errorf("TINYTOE_TARGET_SCHEMA must not be empty")
@@ -5,6 +5,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"tinytoe/internal/config"
 )
@@ -128,3 +129,151 @@ func TestLoadRejectsReservedTargetSchema(t *testing.T) {
 		t.Fatalf("expected reserved schema error, got %v", err)
 	}
 }
+
+func TestLoadDefaultsLockWaitTimeout(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://example.com/db")
+	t.Setenv("TINYTOE_LOCK_WAIT_TIMEOUT", "")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.LockWaitTimeout != config.DefaultLockWaitTimeout {
+		t.Fatalf("expected default lock wait timeout, got %s", cfg.LockWaitTimeout)
+	}
+}
+
+func TestLoadParsesLockWaitTimeoutEnv(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://example.com/db")
+	t.Setenv("TINYTOE_LOCK_WAIT_TIMEOUT", "90s")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.LockWaitTimeout != 90*time.Second {
+		t.Fatalf("expected 90s lock wait timeout, got %s", cfg.LockWaitTimeout)
+	}
+
+	t.Setenv("TINYTOE_LOCK_WAIT_TIMEOUT", "soon")
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "TINYTOE_LOCK_WAIT_TIMEOUT") {
+		t.Fatalf("expected error mentioning TINYTOE_LOCK_WAIT_TIMEOUT, got %v", err)
+	}
+}
+
+func TestLoadParsesConnectTimeoutAndWait(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://example.com/db")
+	t.Setenv("TINYTOE_CONNECT_TIMEOUT", "")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.ConnectTimeout != 0 {
+		t.Fatalf("expected a single connection attempt by default, got %s", cfg.ConnectTimeout)
+	}
+
+	cfg, err = config.LoadWithOptions(config.LoadOptions{Wait: true})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	if cfg.ConnectTimeout != config.DefaultConnectTimeout {
+		t.Fatalf("expected --wait to use the default connect timeout, got %s", cfg.ConnectTimeout)
+	}
+
+	t.Setenv("TINYTOE_CONNECT_TIMEOUT", "5m")
+	cfg, err = config.LoadWithOptions(config.LoadOptions{Wait: true})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	if cfg.ConnectTimeout != 5*time.Minute {
+		t.Fatalf("expected --wait to honor TINYTOE_CONNECT_TIMEOUT, got %s", cfg.ConnectTimeout)
+	}
+
+	override := 10 * time.Second
+	cfg, err = config.LoadWithOptions(config.LoadOptions{Wait: true, ConnectTimeoutOverride: &override})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	if cfg.ConnectTimeout != override {
+		t.Fatalf("expected --wait=10s to win, got %s", cfg.ConnectTimeout)
+	}
+
+	t.Setenv("TINYTOE_CONNECT_TIMEOUT", "later")
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "TINYTOE_CONNECT_TIMEOUT") {
+		t.Fatalf("expected error mentioning TINYTOE_CONNECT_TIMEOUT, got %v", err)
+	}
+}
+
+func TestLoadParsesOutputEnvAndOverride(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://example.com/db")
+	t.Setenv("TINYTOE_OUTPUT", "")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Output != "text" {
+		t.Fatalf("expected text output by default, got %q", cfg.Output)
+	}
+
+	t.Setenv("TINYTOE_OUTPUT", "JSON")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Output != "json" {
+		t.Fatalf("expected json output from env, got %q", cfg.Output)
+	}
+
+	override := "text"
+	cfg, err = config.LoadWithOptions(config.LoadOptions{OutputOverride: &override})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	if cfg.Output != "text" {
+		t.Fatalf("expected override to win, got %q", cfg.Output)
+	}
+
+	t.Setenv("TINYTOE_OUTPUT", "yaml")
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "unsupported output format") {
+		t.Fatalf("expected unsupported output error, got %v", err)
+	}
+}
+
+func TestLoadParsesMigrationTimeouts(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://example.com/db")
+	t.Setenv("TINYTOE_LOCK_TIMEOUT", "")
+	t.Setenv("TINYTOE_STATEMENT_TIMEOUT", "")
+	t.Setenv("TINYTOE_MIGRATION_TIMEOUT", "")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.LockTimeout != 0 || cfg.StatementTimeout != 0 {
+		t.Fatalf("expected server timeouts to default to unset, got lock=%s statement=%s", cfg.LockTimeout, cfg.StatementTimeout)
+	}
+	if cfg.MigrationTimeout != config.DefaultMigrationTimeout {
+		t.Fatalf("expected default migration timeout, got %s", cfg.MigrationTimeout)
+	}
+
+	t.Setenv("TINYTOE_LOCK_TIMEOUT", "5s")
+	t.Setenv("TINYTOE_STATEMENT_TIMEOUT", "1m")
+	t.Setenv("TINYTOE_MIGRATION_TIMEOUT", "0")
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.LockTimeout != 5*time.Second || cfg.StatementTimeout != time.Minute {
+		t.Fatalf("unexpected server timeouts lock=%s statement=%s", cfg.LockTimeout, cfg.StatementTimeout)
+	}
+	if cfg.MigrationTimeout != 0 {
+		t.Fatalf("expected migration timeout to be disabled, got %s", cfg.MigrationTimeout)
+	}
+
+	t.Setenv("TINYTOE_STATEMENT_TIMEOUT", "-1s")
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "TINYTOE_STATEMENT_TIMEOUT") {
+		t.Fatalf("expected error mentioning TINYTOE_STATEMENT_TIMEOUT, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultConfigFile is the project config file read from the working
+// directory when TINYTOE_CONFIG does not name another path.
+const DefaultConfigFile = "tinytoe.toml"
+
+// environmentsTable prefixes the per-environment tables, e.g.
+// [environments.staging].
+const environmentsTable = "environments"
+
+// fileKeys lists the settings accepted in the config file, at the top level
+// (shared by every environment) or inside an environment table.
+var fileKeys = map[string]bool{
+	"database_url":       true,
+	"database_url_env":   true,
+	"target_schema":      true,
+	"migrations_dir":     true,
+	"connect_timeout":    true,
+	"lock_wait_timeout":  true,
+	"lock_timeout":       true,
+	"statement_timeout":  true,
+	"migration_timeout":  true,
+	"protected":          true,
+	"lint_disable":       true,
+	"dump_file":          true,
+	"dump_on_up":         true,
+	"allow_out_of_order": true,
+}
+
+// alternativeKeys pairs settings that cannot appear in the same table.
+var alternativeKeys = map[string]string{
+	"database_url":     "database_url_env",
+	"database_url_env": "database_url",
+}
+
+// fileSetting is a single `key = value` pair with its location for errors.
+type fileSetting struct {
+	value string
+	// quoted reports whether the value was written as a string.
+	quoted bool
+	line   int
+}
+
+// configFile holds the parsed contents of tinytoe.toml.
+type configFile struct {
+	path         string
+	settings     map[string]fileSetting
+	environments map[string]map[string]fileSetting
+}
+
+// loadConfigFile reads and parses path. A missing file yields nil without an
+// error unless required is set.
+func loadConfigFile(path string, required bool) (*configFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open config file: %w", err)
+	}
+	defer file.Close()
+
+	parsed := &configFile{
+		path:         path,
+		settings:     make(map[string]fileSetting),
+		environments: make(map[string]map[string]fileSetting),
+	}
+	current := parsed.settings
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s line %d: unterminated table header", path, lineNumber)
+			}
+			table := strings.TrimSpace(line[1 : len(line)-1])
+			prefix, name, ok := strings.Cut(table, ".")
+			name = strings.TrimSpace(name)
+			if !ok || strings.TrimSpace(prefix) != environmentsTable || !isBareKey(name) {
+				return nil, fmt.Errorf("%s line %d: unsupported table [%s]; expected [%s.<name>]", path, lineNumber, table, environmentsTable)
+			}
+			if _, exists := parsed.environments[name]; exists {
+				return nil, fmt.Errorf("%s line %d: environment %q is defined more than once", path, lineNumber, name)
+			}
+			current = make(map[string]fileSetting)
+			parsed.environments[name] = current
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s line %d: expected key = value", path, lineNumber)
+		}
+		if !fileKeys[key] {
+			return nil, fmt.Errorf("%s line %d: unknown setting %q", path, lineNumber, key)
+		}
+		if _, exists := current[key]; exists {
+			return nil, fmt.Errorf("%s line %d: %s is set more than once", path, lineNumber, key)
+		}
+		if _, exists := current[alternativeKeys[key]]; exists {
+			return nil, fmt.Errorf("%s line %d: set either database_url or database_url_env, not both", path, lineNumber)
+		}
+
+		setting, err := parseFileValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %s: %w", path, lineNumber, key, err)
+		}
+		setting.line = lineNumber
+		current[key] = setting
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	return parsed, nil
+}
+
+// environment merges the top-level settings with those of the named
+// environment. An empty name selects the top-level settings alone.
+func (f *configFile) environment(name string) (map[string]fileSetting, error) {
+	merged := make(map[string]fileSetting, len(f.settings))
+	for key, setting := range f.settings {
+		merged[key] = setting
+	}
+	if name == "" {
+		return merged, nil
+	}
+
+	env, ok := f.environments[name]
+	if !ok {
+		return nil, fmt.Errorf("environment %q is not defined in %s (available: %s)", name, f.path, strings.Join(f.environmentNames(), ", "))
+	}
+	// database_url and database_url_env are alternatives, so an environment
+	// choosing one replaces a shared default of the other.
+	if _, ok := env["database_url"]; ok {
+		delete(merged, "database_url_env")
+	}
+	if _, ok := env["database_url_env"]; ok {
+		delete(merged, "database_url")
+	}
+	for key, setting := range env {
+		merged[key] = setting
+	}
+	return merged, nil
+}
+
+// definesDatabaseURL reports whether the named environment sets its own
+// database_url or database_url_env instead of inheriting the top-level one.
+func (f *configFile) definesDatabaseURL(name string) bool {
+	env := f.environments[name]
+	_, url := env["database_url"]
+	_, ref := env["database_url_env"]
+	return url || ref
+}
+
+func (f *configFile) environmentNames() []string {
+	names := make([]string, 0, len(f.environments))
+	for name := range f.environments {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return []string{"none"}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolvePath interprets a relative path from the file against the directory
+// holding the file, so a config elsewhere still points at its own project.
+func (f *configFile) resolvePath(value string) string {
+	if filepath.IsAbs(value) {
+		return value
+	}
+	return filepath.Join(filepath.Dir(f.path), value)
+}
+
+// parseFileValue accepts the TOML value forms Tiny Toe needs: basic
+// ("...") and literal ('...') strings, booleans and integers.
+func parseFileValue(raw string) (fileSetting, error) {
+	switch {
+	case raw == "":
+		return fileSetting{}, fmt.Errorf("missing value")
+	case strings.HasPrefix(raw, `"`):
+		value, err := strconv.Unquote(raw)
+		if err != nil {
+			return fileSetting{}, fmt.Errorf("invalid string %s", raw)
+		}
+		return fileSetting{value: value, quoted: true}, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") || strings.Contains(raw[1:len(raw)-1], "'") {
+			return fileSetting{}, fmt.Errorf("invalid string %s", raw)
+		}
+		return fileSetting{value: raw[1 : len(raw)-1], quoted: true}, nil
+	case raw == "true" || raw == "false":
+		return fileSetting{value: raw}, nil
+	}
+
+	if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+		return fileSetting{}, fmt.Errorf("unsupported value %s; expected a quoted string, boolean or integer", raw)
+	}
+	return fileSetting{value: raw}, nil
+}
+
+// stripComment removes a trailing # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isBareKey(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r != '_' && r != '-' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
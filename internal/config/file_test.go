@@ -0,0 +1,183 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tinytoe/internal/config"
+)
+
+const sampleConfigFile = `
+# shared by every environment
+migrations_dir = "db/migrations"
+lock_timeout = "3s"
+
+[environments.dev]
+database_url = "postgres://localhost/app_dev" # inline comment
+dump_file = "db/schema.sql"
+allow_out_of_order = true
+
+[environments.prod]
+database_url_env = "TT_TEST_PROD_URL"
+target_schema = 'app'
+lock_timeout = "5s"
+migration_timeout = 0
+protected = true
+dump_on_up = true
+`
+
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tinytoe.toml")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"DATABASE_URL", "TINYTOE_ENV", "TINYTOE_CONFIG", "TINYTOE_TARGET_SCHEMA", "TINYTOE_MIGRATIONS_DIR",
+		"TINYTOE_LOCK_TIMEOUT", "TINYTOE_MIGRATION_TIMEOUT", "TINYTOE_DUMP_FILE", "TINYTOE_DUMP_ON_UP",
+		"TINYTOE_ALLOW_OUT_OF_ORDER",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLoadReadsSelectedEnvironmentFromConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, sampleConfigFile)
+	t.Setenv("TINYTOE_CONFIG", path)
+	t.Setenv("TINYTOE_ENV", "dev")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DatabaseURL != "postgres://localhost/app_dev" {
+		t.Fatalf("expected dev database url, got %q", cfg.DatabaseURL)
+	}
+	if want := filepath.Join(filepath.Dir(path), "db", "migrations"); cfg.MigrationsDir != want {
+		t.Fatalf("expected migrations dir relative to the config file %q, got %q", want, cfg.MigrationsDir)
+	}
+	if cfg.LockTimeout != 3*time.Second || cfg.TargetSchema != "public" || cfg.Protected {
+		t.Fatalf("expected shared settings and defaults for dev, got %+v", cfg)
+	}
+	if want := filepath.Join(filepath.Dir(path), "db", "schema.sql"); cfg.DumpFile != want || cfg.DumpOnUp {
+		t.Fatalf("expected dump file relative to the config file %q without dump_on_up, got %q (%v)", want, cfg.DumpFile, cfg.DumpOnUp)
+	}
+	if !cfg.AllowOutOfOrder {
+		t.Fatalf("expected allow_out_of_order from the dev environment")
+	}
+	if cfg.Environment != "dev" {
+		t.Fatalf("expected environment name to be recorded, got %q", cfg.Environment)
+	}
+
+	t.Setenv("TT_TEST_PROD_URL", "postgres://prod.example.com/app")
+	env := "prod"
+	cfg, err = config.LoadWithOptions(config.LoadOptions{EnvOverride: &env})
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	if cfg.DatabaseURL != "postgres://prod.example.com/app" || cfg.TargetSchema != "app" {
+		t.Fatalf("expected prod settings, got %+v", cfg)
+	}
+	if cfg.LockTimeout != 5*time.Second || cfg.MigrationTimeout != 0 || !cfg.Protected || !cfg.DumpOnUp || cfg.DumpFile != "" || cfg.AllowOutOfOrder {
+		t.Fatalf("expected prod overrides, got %+v", cfg)
+	}
+}
+
+func TestLoadPrefersEnvironmentVariablesOverConfigFile(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("TINYTOE_CONFIG", writeConfigFile(t, sampleConfigFile))
+	t.Setenv("TINYTOE_ENV", "dev")
+	t.Setenv("DATABASE_URL", "postgres://override/db")
+	t.Setenv("TINYTOE_LOCK_TIMEOUT", "9s")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.DatabaseURL != "postgres://override/db" || cfg.LockTimeout != 9*time.Second {
+		t.Fatalf("expected environment variables to win, got %+v", cfg)
+	}
+}
+
+func TestLoadPrefersDatabaseOfEnvironmentSelectedWithFlag(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("TINYTOE_CONFIG", writeConfigFile(t, sampleConfigFile+`
+[environments.staging]
+target_schema = "staging"
+`))
+	t.Setenv("DATABASE_URL", "postgres://localhost/scratch")
+	t.Setenv("TT_TEST_PROD_URL", "postgres://prod.example.com/app")
+
+	for env, want := range map[string]string{
+		"dev":  "postgres://localhost/app_dev",
+		"prod": "postgres://prod.example.com/app",
+		// An environment without a database of its own keeps DATABASE_URL.
+		"staging": "postgres://localhost/scratch",
+	} {
+		env := env
+		cfg, err := config.LoadWithOptions(config.LoadOptions{EnvOverride: &env})
+		if err != nil {
+			t.Fatalf("LoadWithOptions(--env %s): %v", env, err)
+		}
+		if cfg.DatabaseURL != want {
+			t.Fatalf("expected --env %s to use %q, got %q", env, want, cfg.DatabaseURL)
+		}
+	}
+
+	// The environment's variable must be set; DATABASE_URL is no fallback.
+	t.Setenv("TT_TEST_PROD_URL", "")
+	env := "prod"
+	_, err := config.LoadWithOptions(config.LoadOptions{EnvOverride: &env})
+	if err == nil || !strings.Contains(err.Error(), "refers to TT_TEST_PROD_URL, which is not set") {
+		t.Fatalf("expected the unset environment variable to be reported, got %v", err)
+	}
+}
+
+func TestLoadReportsConfigFileProblems(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("DATABASE_URL", "postgres://example.com/db")
+
+	t.Setenv("TINYTOE_CONFIG", writeConfigFile(t, sampleConfigFile))
+	t.Setenv("TINYTOE_ENV", "staging")
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), `environment "staging" is not defined`) {
+		t.Fatalf("expected unknown environment error, got %v", err)
+	}
+
+	t.Setenv("TINYTOE_ENV", "prod")
+	t.Setenv("DATABASE_URL", "")
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "TT_TEST_PROD_URL") {
+		t.Fatalf("expected error naming the unset database_url_env variable, got %v", err)
+	}
+
+	t.Setenv("TINYTOE_ENV", "")
+	t.Setenv("DATABASE_URL", "postgres://example.com/db")
+	t.Setenv("TINYTOE_CONFIG", writeConfigFile(t, "target_schema = \"app\"\nschema = \"typo\"\n"))
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), `line 2: unknown setting "schema"`) {
+		t.Fatalf("expected unknown setting error with line, got %v", err)
+	}
+
+	t.Setenv("TINYTOE_CONFIG", writeConfigFile(t, "protected = \"yes\"\n"))
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "protected") {
+		t.Fatalf("expected protected parse error, got %v", err)
+	}
+
+	t.Setenv("TINYTOE_CONFIG", filepath.Join(t.TempDir(), "missing.toml"))
+	if _, err := config.Load(); err == nil {
+		t.Fatalf("expected error for an explicit config path that does not exist")
+	}
+
+	t.Setenv("TINYTOE_CONFIG", "")
+	t.Setenv("TINYTOE_ENV", "dev")
+	if _, err := config.Load(); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected error when an environment is selected without a config file, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Event is a single newline-delimited JSON record emitted in JSON mode.
+type Event struct {
+	Event    string                 `json:"event"`
+	Command  string                 `json:"command,omitempty"`
+	Result   string                 `json:"result,omitempty"`
+	Message  string                 `json:"message,omitempty"`
+	Details  []EventDetail          `json:"details,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Rows     []EventRow             `json:"rows,omitempty"`
+	Name     string                 `json:"name,omitempty"`
+	Body     string                 `json:"body,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	ExitCode int                    `json:"exit_code,omitempty"`
+}
+
+// EventDetail is the JSON form of a Detail.
+type EventDetail struct {
+	Label string `json:"label,omitempty"`
+	Value string `json:"value,omitempty"`
+	Kind  string `json:"kind"`
+}
+
+// EventRow is the JSON form of a Row.
+type EventRow struct {
+	Columns []string `json:"columns"`
+	Kind    string   `json:"kind"`
+}
+
+// jsonPrinter writes one JSON object per line so tooling can stream events
+// without scraping decorated text.
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func newJSONPrinter(w io.Writer) jsonPrinter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return jsonPrinter{enc: enc}
+}
+
+// WriteError emits an error event. The CLI uses it to report failures in JSON
+// mode so every outcome is machine-readable.
+func WriteError(w io.Writer, command string, err error, exitCode int) {
+	if w == nil || err == nil {
+		return
+	}
+	newJSONPrinter(w).emit(Event{
+		Event:    "error",
+		Command:  normalizeCommand(command),
+		Error:    err.Error(),
+		ExitCode: exitCode,
+	})
+}
+
+func (p jsonPrinter) PrintDelight(block Delight) {
+	details := make([]EventDetail, 0, len(block.Details))
+	for _, detail := range block.Details {
+		details = append(details, EventDetail{
+			Label: strings.TrimSpace(detail.Label),
+			Value: strings.TrimSpace(detail.Value),
+			Kind:  detail.Kind.String(),
+		})
+	}
+	p.emit(Event{
+		Event:   "result",
+		Command: normalizeCommand(block.Command),
+		Result:  strings.TrimSpace(block.Result),
+		Details: details,
+		Data:    block.Data,
+	})
+}
+
+func (p jsonPrinter) PrintWarning(message string) {
+	text := strings.TrimSpace(message)
+	if text == "" {
+		return
+	}
+	p.emit(Event{Event: "warning", Message: text})
+}
+
+func (p jsonPrinter) PrintSuccessLine(format string, args ...interface{}) {
+	message := strings.TrimSpace(fmt.Sprintf(format, args...))
+	if message == "" {
+		return
+	}
+	p.emit(Event{Event: "success", Message: message})
+}
+
+func (p jsonPrinter) PrintRows(rows []Row) {
+	if len(rows) == 0 {
+		return
+	}
+	out := make([]EventRow, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, EventRow{Columns: row.Columns, Kind: row.Kind.String()})
+	}
+	p.emit(Event{Event: "rows", Rows: out})
+}
+
+func (p jsonPrinter) PrintSQL(name, body string) {
+	p.emit(Event{Event: "sql", Name: strings.TrimSpace(name), Body: body})
+}
+
+func (p jsonPrinter) PrintPrompt(prompt string) {
+	p.emit(Event{Event: "prompt", Message: strings.TrimSpace(prompt)})
+}
+
+// PrintBreak is a no-op; events are already line-delimited.
+func (p jsonPrinter) PrintBreak() {}
+
+func (p jsonPrinter) emit(event Event) {
+	// Encoding failures only arise from broken writers, which text output
+	// ignores as well.
+	_ = p.enc.Encode(event)
+}
+
+func normalizeCommand(command string) string {
+	command = strings.TrimSpace(command)
+	return strings.TrimSpace(strings.TrimPrefix(command, "tinytoe"))
+}
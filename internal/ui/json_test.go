@@ -0,0 +1,79 @@
+package ui_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"tinytoe/internal/ui"
+)
+
+func TestJSONPrinterEmitsOneEventPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	printer := ui.NewPrinterWithFormat(&buf, ui.FormatJSON)
+
+	printer.PrintSuccessLine("Applied %s", "20240101010101_add_users.sql")
+	printer.PrintBreak()
+	printer.PrintDelight(ui.Delight{
+		Command: "up",
+		Result:  "migrations applied successfully",
+		Details: []ui.Detail{
+			{Label: "Applied", Value: "1 migration(s)"},
+			{Label: "Heads up", Value: "slow", Kind: ui.DetailWarning},
+		},
+		Data: map[string]interface{}{"applied": []string{"20240101010101_add_users.sql"}},
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 JSON lines, got %d: %q", len(lines), buf.String())
+	}
+
+	var success ui.Event
+	if err := json.Unmarshal([]byte(lines[0]), &success); err != nil {
+		t.Fatalf("decode success event: %v", err)
+	}
+	if success.Event != "success" || success.Message != "Applied 20240101010101_add_users.sql" {
+		t.Fatalf("unexpected success event: %+v", success)
+	}
+
+	var result struct {
+		Event   string           `json:"event"`
+		Command string           `json:"command"`
+		Result  string           `json:"result"`
+		Details []ui.EventDetail `json:"details"`
+		Data    struct {
+			Applied []string `json:"applied"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &result); err != nil {
+		t.Fatalf("decode result event: %v", err)
+	}
+	if result.Event != "result" || result.Command != "up" || result.Result != "migrations applied successfully" {
+		t.Fatalf("unexpected result event: %+v", result)
+	}
+	if len(result.Details) != 2 || result.Details[1].Kind != "warning" {
+		t.Fatalf("unexpected details: %+v", result.Details)
+	}
+	if len(result.Data.Applied) != 1 || result.Data.Applied[0] != "20240101010101_add_users.sql" {
+		t.Fatalf("unexpected data: %+v", result.Data)
+	}
+}
+
+func TestWriteErrorEmitsErrorEvent(t *testing.T) {
+	var buf bytes.Buffer
+	ui.WriteError(&buf, "tinytoe status", errors.New("database has 1 pending migration(s)"), 1)
+
+	var event ui.Event
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("decode error event: %v", err)
+	}
+	if event.Event != "error" || event.Command != "status" || event.ExitCode != 1 {
+		t.Fatalf("unexpected error event: %+v", event)
+	}
+	if event.Error != "database has 1 pending migration(s)" {
+		t.Fatalf("unexpected error message: %q", event.Error)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/term"
 )
@@ -20,6 +21,14 @@ const (
 	DetailWarning
 )
 
+// String names the kind for machine-readable output.
+func (k DetailKind) String() string {
+	if k == DetailWarning {
+		return "warning"
+	}
+	return "info"
+}
+
 // Detail represents a label/value pair rendered as part of a block of output.
 type Detail struct {
 	Label string
@@ -32,29 +41,71 @@ type Delight struct {
 	Command string
 	Result  string
 	Details []Detail
+	// Data carries structured results (applied files, migration states, ...)
+	// for machine-readable output. Text output ignores it.
+	Data map[string]interface{}
 }
 
+// Row is a single line of column-aligned output.
+type Row struct {
+	Columns []string
+	Kind    DetailKind
+}
+
+const (
+	// FormatText selects human-oriented output with optional color.
+	FormatText = "text"
+	// FormatJSON selects newline-delimited JSON events.
+	FormatJSON = "json"
+)
+
 // Printer is responsible for producing consistently styled command output.
-type Printer struct {
-	w           io.Writer
-	useColor    bool
-	colorScheme palette
+type Printer interface {
+	// PrintDelight renders the result block for a command.
+	PrintDelight(block Delight)
+	// PrintWarning renders a highlighted warning line.
+	PrintWarning(message string)
+	// PrintSuccessLine renders a single progress line for a completed step.
+	PrintSuccessLine(format string, args ...interface{})
+	// PrintRows renders column-aligned rows.
+	PrintRows(rows []Row)
+	// PrintSQL renders a named SQL body.
+	PrintSQL(name, body string)
+	// PrintPrompt renders a confirmation prompt ahead of reading user input.
+	PrintPrompt(prompt string)
+	// PrintBreak separates groups of output.
+	PrintBreak()
 }
 
-// NewPrinter constructs a Printer targeting the supplied writer.
+// NewPrinter constructs a text Printer targeting the supplied writer.
 func NewPrinter(w io.Writer) Printer {
+	return NewPrinterWithFormat(w, FormatText)
+}
+
+// NewPrinterWithFormat constructs a Printer for the named output format,
+// falling back to text for unrecognized values.
+func NewPrinterWithFormat(w io.Writer, format string) Printer {
 	if w == nil {
 		w = io.Discard
 	}
-	return Printer{
+	if format == FormatJSON {
+		return newJSONPrinter(w)
+	}
+	return textPrinter{
 		w:           w,
 		useColor:    shouldUseColor(w),
 		colorScheme: defaultPalette(),
 	}
 }
 
+type textPrinter struct {
+	w           io.Writer
+	useColor    bool
+	colorScheme palette
+}
+
 // PrintDelight renders a Delight block using the printer's style rules.
-func (p Printer) PrintDelight(block Delight) {
+func (p textPrinter) PrintDelight(block Delight) {
 	if p.w == nil {
 		return
 	}
@@ -109,8 +160,84 @@ func (p Printer) PrintDelight(block Delight) {
 	fmt.Fprintln(p.w)
 }
 
+// PrintRows renders rows with each column padded to the widest value in that
+// column. Warning rows are highlighted so drift stands out in long listings;
+// when any row is a warning, every row gets a marker column so the columns
+// still line up.
+func (p textPrinter) PrintRows(rows []Row) {
+	if p.w == nil || len(rows) == 0 {
+		return
+	}
+
+	marker := ""
+	var widths []int
+	for _, row := range rows {
+		if row.Kind == DetailWarning {
+			marker = strings.Repeat(" ", warningEmojiWidth+1)
+		}
+		for i, column := range row.Columns {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(column); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	for _, row := range rows {
+		var b strings.Builder
+		for i, column := range row.Columns {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(column)
+			if i < len(row.Columns)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(column)))
+			}
+		}
+		line := strings.TrimRight(b.String(), " ")
+
+		if row.Kind == DetailWarning {
+			fmt.Fprintf(p.w, "  %s\n", p.decorateWarning(fmt.Sprintf("%s %s", WarningEmoji, line)))
+			continue
+		}
+		fmt.Fprintf(p.w, "  %s%s\n", marker, p.decorateValue(line))
+	}
+}
+
+// PrintSQL renders a migration body beneath a SQL comment naming its file so
+// the output can be reviewed or pasted into a SQL client as-is.
+func (p textPrinter) PrintSQL(name, body string) {
+	if p.w == nil {
+		return
+	}
+	fmt.Fprintln(p.w, p.decorateTitle("-- "+strings.TrimSpace(name)))
+	body = strings.TrimRight(body, "\n")
+	if body != "" {
+		fmt.Fprintln(p.w, body)
+	}
+	fmt.Fprintln(p.w)
+}
+
+// PrintPrompt writes the prompt without a trailing newline so input follows it.
+func (p textPrinter) PrintPrompt(prompt string) {
+	if p.w == nil {
+		return
+	}
+	fmt.Fprint(p.w, prompt)
+}
+
+// PrintBreak writes a blank line.
+func (p textPrinter) PrintBreak() {
+	if p.w == nil {
+		return
+	}
+	fmt.Fprintln(p.w)
+}
+
 // PrintWarning renders a highlighted warning line distinct from delight blocks.
-func (p Printer) PrintWarning(message string) {
+func (p textPrinter) PrintWarning(message string) {
 	if p.w == nil {
 		return
 	}
@@ -122,7 +249,7 @@ func (p Printer) PrintWarning(message string) {
 }
 
 // PrintSuccessLine renders a single success line using the message styling.
-func (p Printer) PrintSuccessLine(format string, args ...interface{}) {
+func (p textPrinter) PrintSuccessLine(format string, args ...interface{}) {
 	if p.w == nil {
 		return
 	}
@@ -195,42 +322,42 @@ func defaultPalette() palette {
 	}
 }
 
-func (p Printer) decorateTitle(text string) string {
+func (p textPrinter) decorateTitle(text string) string {
 	if !p.useColor {
 		return text
 	}
 	return p.colorScheme.title + text + resetCode
 }
 
-func (p Printer) decorateMessage(text string) string {
+func (p textPrinter) decorateMessage(text string) string {
 	if !p.useColor {
 		return text
 	}
 	return p.colorScheme.message + text + resetCode
 }
 
-func (p Printer) decorateLabel(text string) string {
+func (p textPrinter) decorateLabel(text string) string {
 	if !p.useColor {
 		return text
 	}
 	return p.colorScheme.label + text + resetCode
 }
 
-func (p Printer) decorateValue(text string) string {
+func (p textPrinter) decorateValue(text string) string {
 	if !p.useColor {
 		return text
 	}
 	return p.colorScheme.value + text + resetCode
 }
 
-func (p Printer) decorateMuted(text string) string {
+func (p textPrinter) decorateMuted(text string) string {
 	if !p.useColor {
 		return text
 	}
 	return p.colorScheme.muted + text + resetCode
 }
 
-func (p Printer) decorateWarning(text string) string {
+func (p textPrinter) decorateWarning(text string) string {
 	if !p.useColor {
 		return text
 	}
@@ -245,6 +372,10 @@ const Arrow = "➜"
 // WarningEmoji is the leading symbol for warning messages.
 const WarningEmoji = "⚠️"
 
+// warningEmojiWidth is how many terminal cells WarningEmoji occupies; it is
+// rendered as a wide emoji thanks to its variation selector.
+const warningEmojiWidth = 2
+
 // SuccessEmoji is the leading symbol for success lines.
 const SuccessEmoji = "✅"
 
@@ -85,3 +85,32 @@ func TestPrinterPrintWarningLine(t *testing.T) {
 		t.Fatalf("unexpected warning output, want %q got %q", want, buf.String())
 	}
 }
+
+func TestPrinterPrintRowsAlignsColumns(t *testing.T) {
+	var buf bytes.Buffer
+	printer := ui.NewPrinter(&buf)
+
+	printer.PrintRows([]ui.Row{
+		{Columns: []string{"20240101010101_a.sql", "pending"}},
+		{Columns: []string{"20240101010202_longer.sql", "missing"}, Kind: ui.DetailWarning},
+	})
+
+	want := "" +
+		"     20240101010101_a.sql       pending\n" +
+		fmt.Sprintf("  %s 20240101010202_longer.sql  missing\n", ui.WarningEmoji)
+	if buf.String() != want {
+		t.Fatalf("unexpected rows output:\nwant:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestPrinterPrintSQL(t *testing.T) {
+	var buf bytes.Buffer
+	printer := ui.NewPrinter(&buf)
+
+	printer.PrintSQL("20240101010101_add_users.sql", "CREATE TABLE users (id INT);\n\n")
+
+	want := "-- 20240101010101_add_users.sql\nCREATE TABLE users (id INT);\n\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected SQL output, want %q got %q", want, buf.String())
+	}
+}
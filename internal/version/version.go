@@ -0,0 +1,21 @@
+package version
+
+import "runtime/debug"
+
+// Version identifies the Tiny Toe build. Release builds set it with
+// -ldflags "-X tinytoe/internal/version.Version=v1.2.3".
+var Version = ""
+
+// String reports the build version, falling back to the module version
+// recorded by `go install` and finally to "dev".
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v := info.Main.Version; v != "" && v != "(devel)" {
+			return v
+		}
+	}
+	return "dev"
+}
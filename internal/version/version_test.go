@@ -0,0 +1,27 @@
+package version_test
+
+import (
+	"testing"
+
+	"tinytoe/internal/version"
+)
+
+func TestStringPrefersLinkerVersion(t *testing.T) {
+	original := version.Version
+	t.Cleanup(func() { version.Version = original })
+
+	version.Version = "v1.2.3"
+	if got := version.String(); got != "v1.2.3" {
+		t.Fatalf("expected linker-provided version, got %q", got)
+	}
+}
+
+func TestStringFallsBackWhenUnset(t *testing.T) {
+	original := version.Version
+	t.Cleanup(func() { version.Version = original })
+
+	version.Version = ""
+	if got := version.String(); got == "" {
+		t.Fatalf("expected a non-empty fallback version")
+	}
+}
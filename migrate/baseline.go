@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"tinytoe/internal/version"
+)
+
+// BaselineOptions tune how Baseline behaves.
+type BaselineOptions struct {
+	// AllowExisting allows baselining a database that already records
+	// migrations; versions that are already recorded are skipped.
+	AllowExisting bool
+}
+
+// BaselineResult describes the outcome of Baseline.
+type BaselineResult struct {
+	// Version is the baseline version.
+	Version string
+	// Baselined lists the migrations newly recorded as applied.
+	Baselined []Migration
+	// Skipped lists migrations through Version that were already recorded.
+	Skipped []Migration
+}
+
+// Baseline records every migration up to and including target as applied
+// without executing it, so Tiny Toe can adopt an existing database. It refuses
+// to touch a database with recorded migrations unless opts.AllowExisting is
+// set.
+func (m *Migrator) Baseline(ctx context.Context, target string, opts BaselineOptions) (*BaselineResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cfg := m.opts
+
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("baseline version is required")
+	}
+
+	baseline, err := m.baselineMigrations(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ping(ctx); err != nil {
+		return nil, err
+	}
+
+	lock, err := acquireMigrationLock(ctx, m.db, cfg.TargetSchema, cfg.LockWaitTimeout, cfg.Hooks.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
+	if err := m.prepare(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := loadAppliedMigrations(ctx, m.db, cfg.TargetSchema)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) > 0 && !opts.AllowExisting {
+		return nil, fmt.Errorf("tinytoe_migrations already records %d migration(s); baseline only runs on an empty history unless --allow-existing is given", len(applied))
+	}
+
+	recorded, err := recordBaseline(ctx, m.db, cfg, baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BaselineResult{Version: target, Baselined: []Migration{}, Skipped: []Migration{}}
+	for _, file := range baseline {
+		if recorded[file.version] {
+			result.Baselined = append(result.Baselined, file.migration())
+		} else {
+			result.Skipped = append(result.Skipped, file.migration())
+		}
+	}
+	return result, nil
+}
+
+// BaselineMigrations lists the migrations that Baseline would record for
+// target, failing when target does not name a migration file.
+func (m *Migrator) BaselineMigrations(target string) ([]Migration, error) {
+	files, err := m.baselineMigrations(strings.TrimSpace(target))
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		migrations = append(migrations, file.migration())
+	}
+	return migrations, nil
+}
+
+func (m *Migrator) baselineMigrations(target string) ([]migrationFile, error) {
+	files, err := discoverMigrations(m.opts.FS, m.opts.DumpFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline []migrationFile
+	found := false
+	for _, file := range files {
+		if file.version > target {
+			break
+		}
+		baseline = append(baseline, file)
+		if file.version == target {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("baseline version %s does not match any migration in the migrations directory", target)
+	}
+	return baseline, nil
+}
+
+// recordBaseline inserts baselined rows in a single transaction, skipping
+// versions that are already recorded. It returns the versions it inserted.
+func recordBaseline(parent context.Context, db *sql.DB, cfg Options, files []migrationFile) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin baseline transaction: %w", err)
+	}
+
+	table := qualifyIdent(cfg.TargetSchema, "tinytoe_migrations")
+	insert := fmt.Sprintf(`
+INSERT INTO %s (version, filename, checksum, applied_by, tinytoe_version, baselined, applied_order)
+VALUES ($1, $2, $3, $4, $5, TRUE, %s)
+ON CONFLICT (version) DO NOTHING`, table, nextAppliedOrder(table))
+
+	recorded := make(map[string]bool, len(files))
+	for _, file := range files {
+		data, err := readMigration(file)
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+		result, err := tx.ExecContext(ctx, insert, file.version, file.filename, checksum(data), cfg.AppliedBy, version.String())
+		if err != nil {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("baseline migration %s: %w", file.filename, err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			recorded[file.version] = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit baseline: %w", err)
+	}
+	return recorded, nil
+}
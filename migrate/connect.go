@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// connectAttemptTimeout bounds a single connection attempt.
+	connectAttemptTimeout = 5 * time.Second
+	// connectInitialBackoff and connectMaxBackoff shape the exponential
+	// backoff between connection attempts.
+	connectInitialBackoff = 500 * time.Millisecond
+	connectMaxBackoff     = 10 * time.Second
+)
+
+// UnreachableError reports that the database could not be reached, after
+// retrying for Options.ConnectTimeout when it is set.
+type UnreachableError struct {
+	// Attempts is the number of connection attempts made.
+	Attempts int
+	// Waited is how long the attempts took in total.
+	Waited time.Duration
+	// Err is the error from the last attempt.
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	if e.Attempts <= 1 {
+		return fmt.Sprintf("connect to database: %v", e.Err)
+	}
+	return fmt.Sprintf("connect to database: still unreachable after %d attempts over %s: %v", e.Attempts, e.Waited.Round(time.Second), e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// ping checks connectivity, retrying with exponential backoff until
+// Options.ConnectTimeout elapses. Errors the server reports while refusing the
+// connection for good (bad credentials, unknown database) are not retried and
+// are returned as they are rather than as an UnreachableError: the database
+// was reached, it just will not let us in.
+func (m *Migrator) ping(ctx context.Context) error {
+	started := time.Now()
+	deadline := started.Add(m.opts.ConnectTimeout)
+	backoff := connectInitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := pingDatabase(ctx, m.db)
+		if err == nil {
+			return nil
+		}
+
+		if !retryableConnectError(err) {
+			return fmt.Errorf("connect to database: %w", err)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 || ctx.Err() != nil {
+			return &UnreachableError{Attempts: attempt, Waited: time.Since(started), Err: err}
+		}
+
+		delay := min(backoff, remaining)
+		if m.opts.Hooks.ConnectRetry != nil {
+			m.opts.Hooks.ConnectRetry(attempt, delay, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return &UnreachableError{Attempts: attempt, Waited: time.Since(started), Err: ctx.Err()}
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, connectMaxBackoff)
+	}
+}
+
+func pingDatabase(parent context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(parent, connectAttemptTimeout)
+	defer cancel()
+
+	return db.PingContext(ctx)
+}
+
+// retryableConnectError reports whether a failed connection attempt may
+// succeed later. Network errors are retried; of the errors the server sends
+// back, only "starting up" and "too many connections" are.
+func retryableConnectError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "57P03" || pgErr.Code == "53300"
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const directivePrefix = "tinytoe:"
+
+// migrationDirectives captures per-file behavior requested through
+// `-- tinytoe:<name>` comments in a migration's leading comment block.
+type migrationDirectives struct {
+	// noTransaction runs the file statement by statement outside a
+	// transaction, for commands such as CREATE INDEX CONCURRENTLY.
+	noTransaction bool
+	// lockTimeout and statementTimeout override the run-wide server-side
+	// timeouts when set; zero disables the timeout for the file.
+	lockTimeout      *time.Duration
+	statementTimeout *time.Duration
+	// timeout overrides the client-side limit for the file; zero disables it.
+	timeout *time.Duration
+	// squash marks a snapshot written by `tinytoe squash`, which stands in
+	// for every migration up to its own version.
+	squash bool
+}
+
+// parseDirectives reads directives from the comment lines at the top of a
+// migration. Scanning stops at the first line that is neither blank nor a
+// comment, so directives cannot hide in the middle of a body.
+func parseDirectives(filename string, data []byte) (migrationDirectives, error) {
+	var directives migrationDirectives
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		if !strings.HasPrefix(comment, directivePrefix) {
+			continue
+		}
+
+		name, value, _ := strings.Cut(strings.TrimPrefix(comment, directivePrefix), "=")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		switch name {
+		case "no-transaction":
+			if value != "" {
+				return migrationDirectives{}, fmt.Errorf("migration %s line %d: directive tinytoe:no-transaction does not take a value", filename, lineNumber)
+			}
+			directives.noTransaction = true
+		case squashDirective:
+			if value != "" {
+				return migrationDirectives{}, fmt.Errorf("migration %s line %d: directive tinytoe:%s does not take a value", filename, lineNumber, squashDirective)
+			}
+			directives.squash = true
+		case "lock-timeout", "statement-timeout", "timeout":
+			duration, err := parseDirectiveDuration(value)
+			if err != nil {
+				return migrationDirectives{}, fmt.Errorf("migration %s line %d: directive tinytoe:%s: %w", filename, lineNumber, name, err)
+			}
+			switch name {
+			case "lock-timeout":
+				directives.lockTimeout = &duration
+			case "statement-timeout":
+				directives.statementTimeout = &duration
+			default:
+				directives.timeout = &duration
+			}
+		case lintIgnoreDirective, lintIgnoreFileDirective:
+			// Read by `tinytoe lint`; no effect when applying.
+		default:
+			return migrationDirectives{}, fmt.Errorf("migration %s line %d: unknown directive tinytoe:%s", filename, lineNumber, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return migrationDirectives{}, fmt.Errorf("read directives from %s: %w", filename, err)
+	}
+
+	return directives, nil
+}
+
+func parseDirectiveDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, fmt.Errorf("expected a duration such as 5s or 10m")
+	}
+	if value == "0" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("duration must not be negative, got %s", value)
+	}
+	return duration, nil
+}
@@ -0,0 +1,871 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaDumpFile is the default name of the schema snapshot written by
+// `tinytoe dump`. Migration discovery ignores it so the snapshot can live in
+// the migrations directory.
+const SchemaDumpFile = "schema.sql"
+
+// dumpHeader opens every snapshot. It carries no timestamp or database name so
+// that dumping the same schema twice produces identical files.
+const dumpHeader = `-- Schema snapshot written by tinytoe dump. Do not edit by hand; run
+-- tinytoe dump to refresh it. Objects are listed by kind and then by name,
+-- with names relative to the target schema.
+`
+
+// bookkeepingTables are Tiny Toe's own tables, which never appear in a dump.
+var bookkeepingTables = []string{"tinytoe_migrations", repeatableTableName, settingsTableName}
+
+// notExtensionMember filters out objects owned by an extension; %s is the
+// catalog (such as pg_class or pg_proc) and the column holding the object's
+// oid.
+const notExtensionMember = `NOT EXISTS (
+    SELECT 1 FROM pg_depend d
+    WHERE d.classid = '%s'::regclass AND d.objid = %s AND d.deptype = 'e')`
+
+// dumpSection is a titled group of statements in a snapshot.
+type dumpSection struct {
+	title      string
+	statements []string
+}
+
+// Dump renders a deterministic DDL snapshot of the target schema from the
+// system catalogs: enum, domain and composite types, sequences, functions,
+// tables and their columns, constraints, indexes, views and triggers. Tiny
+// Toe's bookkeeping tables and objects owned by extensions are left out.
+// Names in the target schema are written unqualified, as migrations usually
+// write them.
+func (m *Migrator) Dump(parent context.Context) (string, error) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if err := m.ping(parent); err != nil {
+		return "", err
+	}
+
+	body, err := dumpSchema(parent, m.db, m.opts.TargetSchema)
+	if err != nil {
+		return "", err
+	}
+	return dumpHeader + "\nSET check_function_bodies = false;\n" + body, nil
+}
+
+// dumpSchema renders the DDL sections of a snapshot of schema, each preceded
+// by a blank line. Function bodies are not validated on replay, so the
+// caller must turn off check_function_bodies first.
+func dumpSchema(parent context.Context, db *sql.DB, schema string) (string, error) {
+	var out strings.Builder
+	err := inspectCatalog(parent, db, schema, func(d *dumper) error {
+		steps := []func() (dumpSection, error){
+			d.types,
+			d.sequences,
+			func() (dumpSection, error) { return d.functions("Functions", functionsBeforeTables) },
+			d.tables,
+			d.sequenceOwners,
+			func() (dumpSection, error) { return d.functions("Functions using tables", functionsAfterTables) },
+			func() (dumpSection, error) { return d.constraints("Constraints", false) },
+			func() (dumpSection, error) { return d.constraints("Foreign keys", true) },
+			d.indexes,
+			d.views,
+			func() (dumpSection, error) { return d.functions("Functions using views", functionsAfterViews) },
+			d.triggers,
+		}
+
+		for _, step := range steps {
+			section, err := step()
+			if err != nil {
+				return err
+			}
+			if len(section.statements) == 0 {
+				continue
+			}
+			fmt.Fprintf(&out, "\n-- %s\n", section.title)
+			for _, stmt := range section.statements {
+				out.WriteString("\n")
+				out.WriteString(stmt)
+				out.WriteString("\n")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// inspectCatalog runs fn against the catalog of schema inside one read-only
+// transaction, so every query sees the same snapshot. With the target schema
+// alone on the search path, the pg_get_* functions leave its objects
+// unqualified.
+func inspectCatalog(parent context.Context, db *sql.DB, schema string, fn func(*dumper) error) error {
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("begin catalog transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL search_path = %s", quoteIdent(schema))); err != nil {
+		return fmt.Errorf("set catalog search_path: %w", err)
+	}
+
+	d := &dumper{ctx: ctx, tx: tx, schema: schema}
+	if err := tx.QueryRowContext(ctx, `SELECT quote_ident($1)`, schema).Scan(&d.prefix); err != nil {
+		return fmt.Errorf("quote schema name: %w", err)
+	}
+	d.prefix += "."
+	return fn(d)
+}
+
+// dumper runs the catalog queries behind Dump and the schema fingerprint.
+type dumper struct {
+	ctx    context.Context
+	tx     *sql.Tx
+	schema string
+	// prefix is the schema name as PostgreSQL quotes it, followed by a dot.
+	prefix string
+}
+
+// unqualify strips the target schema from names that the catalog functions
+// qualify regardless of the search path. A match must start a name, so an
+// identifier that merely ends in the schema name is left alone.
+func (d *dumper) unqualify(def string) string {
+	var out strings.Builder
+	for {
+		i := strings.Index(def, d.prefix)
+		if i < 0 {
+			out.WriteString(def)
+			return out.String()
+		}
+		out.WriteString(def[:i])
+		if i > 0 && (isIdentByte(def[i-1]) || def[i-1] == '"') {
+			out.WriteString(d.prefix)
+		}
+		def = def[i+len(d.prefix):]
+	}
+}
+
+// query runs a catalog query with the target schema as $1, scanning each row
+// with scan.
+func (d *dumper) query(what, query string, scan func(*sql.Rows) error) error {
+	rows, err := d.tx.QueryContext(d.ctx, query, d.schema)
+	if err != nil {
+		return fmt.Errorf("dump %s: %w", what, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("dump %s: %w", what, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("dump %s: %w", what, err)
+	}
+	return nil
+}
+
+// skipBookkeeping is a SQL condition excluding Tiny Toe's tables by the
+// relation name in column.
+func skipBookkeeping(column string) string {
+	names := make([]string, len(bookkeepingTables))
+	for i, name := range bookkeepingTables {
+		names[i] = "'" + name + "'"
+	}
+	return fmt.Sprintf("%s NOT IN (%s)", column, strings.Join(names, ", "))
+}
+
+// types dumps enum, domain and composite types, in that order so that
+// domains and composites can use the enums. A domain over another domain
+// follows the domains that are not.
+func (d *dumper) types() (dumpSection, error) {
+	section := dumpSection{title: "Types"}
+
+	query := `
+SELECT quote_ident(t.typname),
+       COALESCE(string_agg(quote_literal(e.enumlabel), ', ' ORDER BY e.enumsortorder), '')
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+LEFT JOIN pg_enum e ON e.enumtypid = t.oid
+WHERE n.nspname = $1
+  AND t.typtype = 'e'
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_type", "t.oid") + `
+GROUP BY t.oid, t.typname
+ORDER BY t.typname`
+	err := d.query("enum types", query, func(rows *sql.Rows) error {
+		var name, labels string
+		if err := rows.Scan(&name, &labels); err != nil {
+			return err
+		}
+		section.statements = append(section.statements, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", name, labels))
+		return nil
+	})
+	if err != nil {
+		return section, err
+	}
+
+	query = `
+SELECT quote_ident(t.typname), format_type(t.typbasetype, t.typtypmod),
+       COALESCE(CASE WHEN t.typcollation <> b.typcollation THEN quote_ident(co.collname) END, ''),
+       COALESCE(t.typdefault, ''), t.typnotnull,
+       COALESCE((
+         SELECT string_agg(format('CONSTRAINT %s %s', quote_ident(con.conname), pg_get_constraintdef(con.oid, true)), E'\n    ' ORDER BY con.conname)
+         FROM pg_constraint con
+         WHERE con.contypid = t.oid AND con.contype = 'c'), '')
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+JOIN pg_type b ON b.oid = t.typbasetype
+LEFT JOIN pg_collation co ON co.oid = t.typcollation
+WHERE n.nspname = $1
+  AND t.typtype = 'd'
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_type", "t.oid") + `
+ORDER BY b.typtype = 'd', t.typname`
+	err = d.query("domains", query, func(rows *sql.Rows) error {
+		var (
+			name, baseType, collation, defaultExpr, checks string
+			notNull                                        bool
+		)
+		if err := rows.Scan(&name, &baseType, &collation, &defaultExpr, &notNull, &checks); err != nil {
+			return err
+		}
+		stmt := "CREATE DOMAIN " + name + " AS " + baseType
+		if collation != "" {
+			stmt += " COLLATE " + collation
+		}
+		if defaultExpr != "" {
+			stmt += " DEFAULT " + d.unqualify(defaultExpr)
+		}
+		if notNull {
+			stmt += " NOT NULL"
+		}
+		if checks != "" {
+			stmt += "\n    " + d.unqualify(checks)
+		}
+		section.statements = append(section.statements, stmt+";")
+		return nil
+	})
+	if err != nil {
+		return section, err
+	}
+
+	// Composite types created with CREATE TYPE are backed by a relation of
+	// kind 'c'; the row types of tables and views come back with them.
+	query = `
+SELECT quote_ident(t.typname),
+       COALESCE(string_agg(quote_ident(a.attname) || ' ' || format_type(a.atttypid, a.atttypmod)
+         || CASE WHEN a.attcollation <> at.typcollation THEN ' COLLATE ' || quote_ident(co.collname) ELSE '' END,
+         E',\n    ' ORDER BY a.attnum), '')
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+JOIN pg_class c ON c.oid = t.typrelid AND c.relkind = 'c'
+LEFT JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+LEFT JOIN pg_type at ON at.oid = a.atttypid
+LEFT JOIN pg_collation co ON co.oid = a.attcollation
+WHERE n.nspname = $1
+  AND t.typtype = 'c'
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_type", "t.oid") + `
+GROUP BY t.oid, t.typname
+ORDER BY t.typname`
+	err = d.query("composite types", query, func(rows *sql.Rows) error {
+		var name, attributes string
+		if err := rows.Scan(&name, &attributes); err != nil {
+			return err
+		}
+		stmt := "CREATE TYPE " + name + " AS ("
+		if attributes != "" {
+			stmt += "\n    " + attributes + "\n"
+		}
+		section.statements = append(section.statements, stmt+");")
+		return nil
+	})
+	return section, err
+}
+
+func (d *dumper) sequences() (dumpSection, error) {
+	section := dumpSection{title: "Sequences"}
+	// Identity sequences are internal to their column ('i') and come back
+	// with the column definition. Sequences a column owns ('a') are tied to
+	// it once the tables exist.
+	query := `
+SELECT quote_ident(c.relname), format_type(s.seqtypid, NULL),
+       s.seqstart, s.seqincrement, s.seqmin, s.seqmax, s.seqcache, s.seqcycle
+FROM pg_sequence s
+JOIN pg_class c ON c.oid = s.seqrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND NOT EXISTS (
+    SELECT 1 FROM pg_depend d
+    WHERE d.classid = 'pg_class'::regclass AND d.objid = c.oid AND d.deptype IN ('e', 'i'))
+ORDER BY c.relname`
+	err := d.query("sequences", query, func(rows *sql.Rows) error {
+		var (
+			name, dataType                     string
+			start, increment, minimum, maximum int64
+			cache                              int64
+			cycle                              bool
+		)
+		if err := rows.Scan(&name, &dataType, &start, &increment, &minimum, &maximum, &cache, &cycle); err != nil {
+			return err
+		}
+		stmt := fmt.Sprintf("CREATE SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d CACHE %d",
+			name, dataType, start, increment, minimum, maximum, cache)
+		if cycle {
+			stmt += " CYCLE"
+		}
+		section.statements = append(section.statements, stmt+";")
+		return nil
+	})
+	return section, err
+}
+
+// Functions are dumped in three groups, each after the relations that their
+// argument and result types or SQL-standard bodies depend on. Functions that
+// depend on no table or view come first, so column defaults can call them.
+const (
+	functionsBeforeTables = iota
+	functionsAfterTables
+	functionsAfterViews
+)
+
+// functions dumps the functions and procedures of one group. A dependency on
+// a relation's row type, or on an array of it, counts as a dependency on the
+// relation.
+func (d *dumper) functions(title string, group int) (dumpSection, error) {
+	section := dumpSection{title: title}
+	query := `
+SELECT pg_get_functiondef(p.oid),
+       COALESCE((
+         SELECT max(CASE WHEN r.relkind IN ('v', 'm') THEN 2 ELSE 1 END)
+         FROM pg_depend dep
+         LEFT JOIN pg_type rt ON dep.refclassid = 'pg_type'::regclass AND rt.oid = dep.refobjid
+         LEFT JOIN pg_type et ON et.oid = rt.typelem
+         JOIN pg_class r ON r.oid = CASE WHEN dep.refclassid = 'pg_class'::regclass THEN dep.refobjid
+                                         ELSE COALESCE(NULLIF(rt.typrelid, 0), et.typrelid) END
+         WHERE dep.classid = 'pg_proc'::regclass AND dep.objid = p.oid
+           AND r.relnamespace = n.oid
+           AND r.relkind IN ('r', 'p', 'v', 'm')), 0)
+FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = $1
+  AND p.prokind IN ('f', 'p')
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_proc", "p.oid") + `
+ORDER BY p.proname, pg_get_function_identity_arguments(p.oid)`
+	err := d.query(strings.ToLower(title), query, func(rows *sql.Rows) error {
+		var def string
+		var dependsOn int
+		if err := rows.Scan(&def, &dependsOn); err != nil {
+			return err
+		}
+		if dependsOn != group {
+			return nil
+		}
+		// pg_get_functiondef always qualifies the function name; only its
+		// first line is rewritten so the body is dumped verbatim.
+		header, body, _ := strings.Cut(def, "\n")
+		section.statements = append(section.statements, d.unqualify(header)+"\n"+strings.TrimRight(body, "\n")+";")
+		return nil
+	})
+	return section, err
+}
+
+// dumpColumn is one column of a table being dumped.
+type dumpColumn struct {
+	name, dataType, collation, defaultExpr string
+	notNull                                bool
+	identity, generated                    string
+}
+
+func (c dumpColumn) String() string {
+	return c.name + " " + c.definition()
+}
+
+// definition renders the column without its name.
+func (c dumpColumn) definition() string {
+	def := c.dataType
+	if c.collation != "" {
+		def += " COLLATE " + c.collation
+	}
+	switch {
+	case c.generated == "s":
+		def += fmt.Sprintf(" GENERATED ALWAYS AS (%s) STORED", c.defaultExpr)
+	case c.identity == "a":
+		def += " GENERATED ALWAYS AS IDENTITY"
+	case c.identity == "d":
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	case c.defaultExpr != "":
+		def += " DEFAULT " + c.defaultExpr
+	}
+	if c.notNull {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// columns loads the columns of every table in the target schema, keyed by
+// table name and in column order.
+func (d *dumper) columns() (map[string][]dumpColumn, error) {
+	columns := make(map[string][]dumpColumn)
+	query := `
+SELECT c.relname, quote_ident(a.attname), format_type(a.atttypid, a.atttypmod),
+       COALESCE(CASE WHEN a.attcollation <> t.typcollation THEN quote_ident(co.collname) END, ''),
+       COALESCE(pg_get_expr(ad.adbin, ad.adrelid), ''), a.attnotnull,
+       a.attidentity::text, a.attgenerated::text
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_type t ON t.oid = a.atttypid
+LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+LEFT JOIN pg_collation co ON co.oid = a.attcollation
+WHERE n.nspname = $1
+  AND c.relkind IN ('r', 'p')
+  AND a.attnum > 0
+  AND NOT a.attisdropped
+ORDER BY c.relname, a.attnum`
+	err := d.query("columns", query, func(rows *sql.Rows) error {
+		var table string
+		var col dumpColumn
+		if err := rows.Scan(&table, &col.name, &col.dataType, &col.collation, &col.defaultExpr, &col.notNull, &col.identity, &col.generated); err != nil {
+			return err
+		}
+		col.defaultExpr = d.unqualify(col.defaultExpr)
+		columns[table] = append(columns[table], col)
+		return nil
+	})
+	return columns, err
+}
+
+func (d *dumper) tables() (dumpSection, error) {
+	section := dumpSection{title: "Tables"}
+
+	columns, err := d.columns()
+	if err != nil {
+		return section, err
+	}
+
+	// Partitions follow every ordinary table so their parents exist first.
+	query := `
+SELECT c.relname, quote_ident(c.relname), c.relispartition,
+       COALESCE(pg_get_partkeydef(c.oid), ''),
+       COALESCE(quote_ident(parent.relname), ''),
+       COALESCE(pg_get_expr(c.relpartbound, c.oid), '')
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_inherits i ON i.inhrelid = c.oid AND c.relispartition
+LEFT JOIN pg_class parent ON parent.oid = i.inhparent
+WHERE n.nspname = $1
+  AND c.relkind IN ('r', 'p')
+  AND ` + skipBookkeeping("c.relname") + `
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "c.oid") + `
+ORDER BY c.relispartition, c.relname`
+	err = d.query("tables", query, func(rows *sql.Rows) error {
+		var (
+			relname, name, partitionKey, parent, bound string
+			partition                                  bool
+		)
+		if err := rows.Scan(&relname, &name, &partition, &partitionKey, &parent, &bound); err != nil {
+			return err
+		}
+		if partition {
+			section.statements = append(section.statements, fmt.Sprintf("CREATE TABLE %s PARTITION OF %s %s;", name, parent, bound))
+			return nil
+		}
+
+		defs := make([]string, 0, len(columns[relname]))
+		for _, col := range columns[relname] {
+			defs = append(defs, "    "+col.String())
+		}
+		stmt := "CREATE TABLE " + name + " ("
+		if len(defs) > 0 {
+			stmt += "\n" + strings.Join(defs, ",\n") + "\n"
+		}
+		stmt += ")"
+		if partitionKey != "" {
+			stmt += " PARTITION BY " + partitionKey
+		}
+		section.statements = append(section.statements, stmt+";")
+		return nil
+	})
+	return section, err
+}
+
+// sequenceOwners ties sequences to the columns that own them ('a'), as serial
+// columns and ALTER SEQUENCE ... OWNED BY do, so dropping the column still
+// drops its sequence. PostgreSQL keeps such a sequence in its table's schema.
+func (d *dumper) sequenceOwners() (dumpSection, error) {
+	section := dumpSection{title: "Sequence ownership"}
+	query := `
+SELECT quote_ident(s.relname), quote_ident(t.relname), quote_ident(a.attname)
+FROM pg_class s
+JOIN pg_namespace n ON n.oid = s.relnamespace
+JOIN pg_depend dep ON dep.classid = 'pg_class'::regclass AND dep.objid = s.oid
+                  AND dep.refclassid = 'pg_class'::regclass AND dep.deptype = 'a'
+JOIN pg_class t ON t.oid = dep.refobjid
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = dep.refobjsubid
+WHERE n.nspname = $1
+  AND s.relkind = 'S'
+  AND ` + skipBookkeeping("t.relname") + `
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "s.oid") + `
+ORDER BY s.relname`
+	err := d.query("sequence ownership", query, func(rows *sql.Rows) error {
+		var sequence, table, column string
+		if err := rows.Scan(&sequence, &table, &column); err != nil {
+			return err
+		}
+		section.statements = append(section.statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s;", sequence, table, column))
+		return nil
+	})
+	return section, err
+}
+
+// constraints dumps table constraints other than NOT NULL, which is part of
+// the column definitions. Foreign keys get their own section so that every
+// table they reference already exists.
+func (d *dumper) constraints(title string, foreign bool) (dumpSection, error) {
+	section := dumpSection{title: title}
+	kinds := "'p', 'u', 'c', 'x'"
+	if foreign {
+		kinds = "'f'"
+	}
+	query := `
+SELECT quote_ident(c.relname), quote_ident(con.conname), pg_get_constraintdef(con.oid, true)
+FROM pg_constraint con
+JOIN pg_class c ON c.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND con.contype IN (` + kinds + `)
+  AND con.conislocal
+  AND c.relkind IN ('r', 'p')
+  AND ` + skipBookkeeping("c.relname") + `
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "c.oid") + `
+ORDER BY c.relname, position(con.contype::text IN 'pucxf'), con.conname`
+	err := d.query(strings.ToLower(title), query, func(rows *sql.Rows) error {
+		var table, name, def string
+		if err := rows.Scan(&table, &name, &def); err != nil {
+			return err
+		}
+		section.statements = append(section.statements,
+			fmt.Sprintf("ALTER TABLE ONLY %s\n    ADD CONSTRAINT %s %s;", table, name, d.unqualify(def)))
+		return nil
+	})
+	return section, err
+}
+
+// indexes dumps indexes that no constraint owns; primary key, unique and
+// exclusion constraints bring their own. Indexes attached to a partitioned
+// parent index come back with the parent.
+func (d *dumper) indexes() (dumpSection, error) {
+	section := dumpSection{title: "Indexes"}
+	query := `
+SELECT pg_get_indexdef(i.oid)
+FROM pg_index x
+JOIN pg_class i ON i.oid = x.indexrelid
+JOIN pg_class t ON t.oid = x.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+WHERE n.nspname = $1
+  AND t.relkind IN ('r', 'p', 'm')
+  AND NOT i.relispartition
+  AND ` + skipBookkeeping("t.relname") + `
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "t.oid") + `
+  AND NOT EXISTS (
+    SELECT 1 FROM pg_constraint con
+    WHERE con.conrelid = t.oid AND con.conindid = i.oid AND con.contype IN ('p', 'u', 'x'))
+ORDER BY t.relname, i.relname`
+	err := d.query("indexes", query, func(rows *sql.Rows) error {
+		var def string
+		if err := rows.Scan(&def); err != nil {
+			return err
+		}
+		section.statements = append(section.statements, d.unqualify(def)+";")
+		return nil
+	})
+	return section, err
+}
+
+// dumpView is a view or materialized view along with the other views in the
+// target schema that it reads from.
+type dumpView struct {
+	name, quoted string
+	def          string
+	materialized bool
+	dependsOn    []string
+}
+
+func (d *dumper) views() (dumpSection, error) {
+	section := dumpSection{title: "Views"}
+
+	views := make(map[string]*dumpView)
+	var names []string
+	query := `
+SELECT c.relname, quote_ident(c.relname), c.relkind = 'm', pg_get_viewdef(c.oid, true)
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND c.relkind IN ('v', 'm')
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "c.oid") + `
+ORDER BY c.relname`
+	err := d.query("views", query, func(rows *sql.Rows) error {
+		view := &dumpView{}
+		if err := rows.Scan(&view.name, &view.quoted, &view.materialized, &view.def); err != nil {
+			return err
+		}
+		views[view.name] = view
+		names = append(names, view.name)
+		return nil
+	})
+	if err != nil || len(names) == 0 {
+		return section, err
+	}
+
+	query = `
+SELECT DISTINCT v.relname, ref.relname
+FROM pg_rewrite r
+JOIN pg_class v ON v.oid = r.ev_class
+JOIN pg_namespace n ON n.oid = v.relnamespace
+JOIN pg_depend d ON d.classid = 'pg_rewrite'::regclass AND d.objid = r.oid
+                AND d.refclassid = 'pg_class'::regclass
+JOIN pg_class ref ON ref.oid = d.refobjid
+WHERE n.nspname = $1
+  AND v.relkind IN ('v', 'm')
+  AND ref.relkind IN ('v', 'm')
+  AND ref.relnamespace = v.relnamespace
+  AND ref.oid <> v.oid
+ORDER BY 1, 2`
+	err = d.query("view dependencies", query, func(rows *sql.Rows) error {
+		var view, ref string
+		if err := rows.Scan(&view, &ref); err != nil {
+			return err
+		}
+		if v, ok := views[view]; ok {
+			v.dependsOn = append(v.dependsOn, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return section, err
+	}
+
+	for _, view := range orderViews(names, views) {
+		kind := "VIEW"
+		if view.materialized {
+			kind = "MATERIALIZED VIEW"
+		}
+		def := strings.TrimSuffix(strings.TrimSpace(d.unqualify(view.def)), ";")
+		stmt := fmt.Sprintf("CREATE %s %s AS\n%s", kind, view.quoted, def)
+		if view.materialized {
+			stmt += "\nWITH NO DATA"
+		}
+		section.statements = append(section.statements, stmt+";")
+	}
+	return section, nil
+}
+
+// triggers dumps the triggers on tables and views, after every function they
+// call and every relation they sit on. Triggers PostgreSQL creates itself,
+// for foreign keys or on partitions from a partitioned parent, are left out.
+func (d *dumper) triggers() (dumpSection, error) {
+	section := dumpSection{title: "Triggers"}
+	query := `
+SELECT pg_get_triggerdef(tg.oid, true)
+FROM pg_trigger tg
+JOIN pg_class c ON c.oid = tg.tgrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND NOT tg.tgisinternal
+  AND ` + skipBookkeeping("c.relname") + `
+  AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "c.oid") + `
+  AND NOT EXISTS (
+    SELECT 1 FROM pg_depend d
+    WHERE d.classid = 'pg_trigger'::regclass AND d.objid = tg.oid AND d.deptype = 'P')
+ORDER BY c.relname, tg.tgname`
+	err := d.query("triggers", query, func(rows *sql.Rows) error {
+		var def string
+		if err := rows.Scan(&def); err != nil {
+			return err
+		}
+		section.statements = append(section.statements, d.unqualify(def)+";")
+		return nil
+	})
+	return section, err
+}
+
+// unsupported lists what a snapshot of the target schema would silently lose:
+// rows in its tables, advanced sequences, and objects the dump does not
+// render, each described as "<kind> <name>". Comments, grants and ownership
+// are not listed.
+func (d *dumper) unsupported() ([]string, error) {
+	var lost []string
+	query := `
+SELECT kind || ' ' || name FROM (
+  SELECT 'foreign table' AS kind, c.relname::text AS name
+  FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = $1 AND c.relkind = 'f'
+    AND ` + fmt.Sprintf(notExtensionMember, "pg_class", "c.oid") + `
+  UNION ALL
+  SELECT 'unlogged table', c.relname
+  FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND c.relpersistence = 'u'
+  UNION ALL
+  SELECT 'inheriting table', c.relname
+  FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = $1 AND NOT c.relispartition
+  UNION ALL
+  SELECT 'row-level security on', c.relname
+  FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = $1 AND c.relrowsecurity
+  UNION ALL
+  SELECT 'policy', c.relname || '.' || p.polname
+  FROM pg_policy p JOIN pg_class c ON c.oid = p.polrelid JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = $1
+  UNION ALL
+  SELECT 'rule', c.relname || '.' || r.rulename
+  FROM pg_rewrite r JOIN pg_class c ON c.oid = r.ev_class JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = $1 AND r.rulename <> '_RETURN'
+  UNION ALL
+  SELECT 'disabled trigger', c.relname || '.' || tg.tgname
+  FROM pg_trigger tg JOIN pg_class c ON c.oid = tg.tgrelid JOIN pg_namespace n ON n.oid = c.relnamespace
+  WHERE n.nspname = $1 AND NOT tg.tgisinternal AND tg.tgenabled <> 'O'
+  UNION ALL
+  SELECT CASE p.prokind WHEN 'a' THEN 'aggregate' ELSE 'window function' END, p.proname
+  FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+  WHERE n.nspname = $1 AND p.prokind IN ('a', 'w')
+    AND ` + fmt.Sprintf(notExtensionMember, "pg_proc", "p.oid") + `
+  UNION ALL
+  SELECT CASE t.typtype WHEN 'r' THEN 'range type' ELSE 'base type' END, t.typname
+  FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+  WHERE n.nspname = $1 AND t.typtype IN ('b', 'r', 'p')
+    AND NOT EXISTS (SELECT 1 FROM pg_type elem WHERE elem.typarray = t.oid)
+    AND ` + fmt.Sprintf(notExtensionMember, "pg_type", "t.oid") + `
+  UNION ALL
+  SELECT 'collation', co.collname
+  FROM pg_collation co JOIN pg_namespace n ON n.oid = co.collnamespace
+  WHERE n.nspname = $1 AND ` + fmt.Sprintf(notExtensionMember, "pg_collation", "co.oid") + `
+  UNION ALL
+  SELECT 'operator', o.oprname
+  FROM pg_operator o JOIN pg_namespace n ON n.oid = o.oprnamespace
+  WHERE n.nspname = $1 AND ` + fmt.Sprintf(notExtensionMember, "pg_operator", "o.oid") + `
+  UNION ALL
+  SELECT 'operator class', oc.opcname
+  FROM pg_opclass oc JOIN pg_namespace n ON n.oid = oc.opcnamespace
+  WHERE n.nspname = $1 AND ` + fmt.Sprintf(notExtensionMember, "pg_opclass", "oc.oid") + `
+  UNION ALL
+  SELECT 'text search configuration', ts.cfgname
+  FROM pg_ts_config ts JOIN pg_namespace n ON n.oid = ts.cfgnamespace
+  WHERE n.nspname = $1 AND ` + fmt.Sprintf(notExtensionMember, "pg_ts_config", "ts.oid") + `
+  UNION ALL
+  SELECT 'text search dictionary', td.dictname
+  FROM pg_ts_dict td JOIN pg_namespace n ON n.oid = td.dictnamespace
+  WHERE n.nspname = $1 AND ` + fmt.Sprintf(notExtensionMember, "pg_ts_dict", "td.oid") + `
+  UNION ALL
+  SELECT 'statistics object', st.stxname
+  FROM pg_statistic_ext st JOIN pg_namespace n ON n.oid = st.stxnamespace
+  WHERE n.nspname = $1
+  UNION ALL
+  SELECT 'conversion', cv.conname
+  FROM pg_conversion cv JOIN pg_namespace n ON n.oid = cv.connamespace
+  WHERE n.nspname = $1 AND ` + fmt.Sprintf(notExtensionMember, "pg_conversion", "cv.oid") + `
+  UNION ALL
+  SELECT 'advanced sequence', s.sequencename
+  FROM pg_sequences s
+  WHERE s.schemaname = $1 AND s.last_value IS NOT NULL
+) lost
+ORDER BY 1`
+	err := d.query("unsupported objects", query, func(rows *sql.Rows) error {
+		var item string
+		if err := rows.Scan(&item); err != nil {
+			return err
+		}
+		lost = append(lost, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Tables are probed one at a time; the dump never carries data.
+	var tables []string
+	query = `
+SELECT c.relname
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND c.relkind = 'r'
+  AND ` + skipBookkeeping("c.relname") + `
+ORDER BY c.relname`
+	err = d.query("tables", query, func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		tables = append(tables, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		var populated bool
+		probe := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM ONLY %s)", qualifyIdent(d.schema, table))
+		if err := d.tx.QueryRowContext(d.ctx, probe).Scan(&populated); err != nil {
+			return nil, fmt.Errorf("dump rows of %s: %w", table, err)
+		}
+		if populated {
+			lost = append(lost, "rows in "+table)
+		}
+	}
+	return lost, nil
+}
+
+// orderViews sorts views by name, except that a view always follows the views
+// it reads from. names must already be sorted.
+func orderViews(names []string, views map[string]*dumpView) []*dumpView {
+	ordered := make([]*dumpView, 0, len(names))
+	emitted := make(map[string]bool, len(names))
+	for len(ordered) < len(names) {
+		progress := false
+		for _, name := range names {
+			if emitted[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range views[name].dependsOn {
+				if _, known := views[dep]; known && !emitted[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, views[name])
+				emitted[name] = true
+				progress = true
+				break
+			}
+		}
+		if !progress {
+			// A dependency cycle cannot happen in a valid catalog; fall back
+			// to name order for whatever is left.
+			rest := make([]string, 0, len(names)-len(ordered))
+			for _, name := range names {
+				if !emitted[name] {
+					rest = append(rest, name)
+				}
+			}
+			sort.Strings(rest)
+			for _, name := range rest {
+				ordered = append(ordered, views[name])
+			}
+			break
+		}
+	}
+	return ordered
+}
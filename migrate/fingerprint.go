@@ -0,0 +1,333 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fingerprintSetting is the tinytoe_settings row holding the catalog
+// fingerprint recorded by the last Up that changed the schema.
+const fingerprintSetting = "schema_fingerprint"
+
+// SchemaObject is one entry of a catalog fingerprint: a table or view, a
+// column, a constraint or an index, with its definition as PostgreSQL
+// renders it.
+type SchemaObject struct {
+	// Kind is "table", "view", "materialized view", "column", "constraint"
+	// or "index".
+	Kind string `json:"kind"`
+	// Name identifies the object; columns and constraints are prefixed with
+	// their table, as in "widgets.name".
+	Name string `json:"name"`
+	// Definition describes the object, e.g. "text NOT NULL" for a column.
+	Definition string `json:"definition"`
+}
+
+// SchemaChange is a difference between the recorded fingerprint and the
+// current catalog.
+type SchemaChange struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Change is "added", "removed" or "changed".
+	Change string `json:"change"`
+	// Recorded and Current hold the definition before and after; one of
+	// them is empty for added and removed objects.
+	Recorded string `json:"recorded,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+// SchemaCheckResult describes the outcome of CheckSchema.
+type SchemaCheckResult struct {
+	// Recorded reports whether a fingerprint has been recorded at all.
+	Recorded bool
+	// RecordedAt is when the fingerprint was last recorded.
+	RecordedAt time.Time
+	// Fingerprint is a hash of the current catalog.
+	Fingerprint string
+	// Objects counts the objects in the current catalog.
+	Objects int
+	// Changes lists what differs from the recorded fingerprint, sorted by
+	// kind and name.
+	Changes []SchemaChange
+}
+
+// CheckSchema compares the target schema's catalog with the fingerprint
+// recorded by the last Up, reporting changes made outside Tiny Toe such as a
+// hand-run ALTER TABLE.
+func (m *Migrator) CheckSchema(ctx context.Context) (*SchemaCheckResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := m.ping(ctx); err != nil {
+		return nil, err
+	}
+
+	schema := m.opts.TargetSchema
+	recorded, recordedAt, found, err := loadSchemaFingerprint(ctx, m.db, schema)
+	if err != nil {
+		return nil, err
+	}
+	current, err := schemaFingerprint(ctx, m.db, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SchemaCheckResult{
+		Recorded:    found,
+		RecordedAt:  recordedAt,
+		Fingerprint: fingerprintHash(current),
+		Objects:     len(current),
+		Changes:     []SchemaChange{},
+	}
+	if found {
+		result.Changes = diffSchemaObjects(recorded, current)
+	}
+	return result, nil
+}
+
+// schemaFingerprint lists the objects in schema, sorted by kind and name.
+// Tiny Toe's bookkeeping tables are left out.
+func schemaFingerprint(ctx context.Context, db *sql.DB, schema string) ([]SchemaObject, error) {
+	var objects []SchemaObject
+	err := inspectCatalog(ctx, db, schema, func(d *dumper) error {
+		add := func(kind, name, definition string) {
+			objects = append(objects, SchemaObject{Kind: kind, Name: name, Definition: definition})
+		}
+
+		query := `
+SELECT c.relname, c.relkind::text,
+       CASE WHEN c.relkind IN ('v', 'm') THEN pg_get_viewdef(c.oid, true) ELSE '' END
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND c.relkind IN ('r', 'p', 'v', 'm')
+  AND ` + skipBookkeeping("c.relname")
+		err := d.query("relations", query, func(rows *sql.Rows) error {
+			var name, relkind, def string
+			if err := rows.Scan(&name, &relkind, &def); err != nil {
+				return err
+			}
+			switch relkind {
+			case "v":
+				add("view", name, strings.TrimSpace(d.unqualify(def)))
+			case "m":
+				add("materialized view", name, strings.TrimSpace(d.unqualify(def)))
+			case "p":
+				add("table", name, "partitioned")
+			default:
+				add("table", name, "")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		columns, err := d.columns()
+		if err != nil {
+			return err
+		}
+		for table, cols := range columns {
+			if isBookkeepingTable(table) {
+				continue
+			}
+			for _, col := range cols {
+				add("column", table+"."+col.name, col.definition())
+			}
+		}
+
+		query = `
+SELECT c.relname, con.conname, pg_get_constraintdef(con.oid, true)
+FROM pg_constraint con
+JOIN pg_class c ON c.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+  AND ` + skipBookkeeping("c.relname")
+		err = d.query("constraints", query, func(rows *sql.Rows) error {
+			var table, name, def string
+			if err := rows.Scan(&table, &name, &def); err != nil {
+				return err
+			}
+			add("constraint", table+"."+name, d.unqualify(def))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		query = `
+SELECT i.relname, pg_get_indexdef(i.oid)
+FROM pg_index x
+JOIN pg_class i ON i.oid = x.indexrelid
+JOIN pg_class t ON t.oid = x.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+WHERE n.nspname = $1
+  AND ` + skipBookkeeping("t.relname")
+		return d.query("indexes", query, func(rows *sql.Rows) error {
+			var name, def string
+			if err := rows.Scan(&name, &def); err != nil {
+				return err
+			}
+			add("index", name, d.unqualify(def))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].Kind != objects[j].Kind {
+			return objects[i].Kind < objects[j].Kind
+		}
+		return objects[i].Name < objects[j].Name
+	})
+	return objects, nil
+}
+
+func isBookkeepingTable(name string) bool {
+	for _, table := range bookkeepingTables {
+		if name == table {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprintHash is a short, stable digest of a fingerprint for display.
+func fingerprintHash(objects []SchemaObject) string {
+	data, _ := json.Marshal(objects)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// diffSchemaObjects reports what was added, removed or redefined between two
+// fingerprints, sorted by kind and name.
+func diffSchemaObjects(recorded, current []SchemaObject) []SchemaChange {
+	key := func(object SchemaObject) string { return object.Kind + "\x00" + object.Name }
+
+	before := make(map[string]SchemaObject, len(recorded))
+	for _, object := range recorded {
+		before[key(object)] = object
+	}
+
+	changes := []SchemaChange{}
+	for _, object := range current {
+		old, ok := before[key(object)]
+		delete(before, key(object))
+		switch {
+		case !ok:
+			changes = append(changes, SchemaChange{Kind: object.Kind, Name: object.Name, Change: "added", Current: object.Definition})
+		case old.Definition != object.Definition:
+			changes = append(changes, SchemaChange{Kind: object.Kind, Name: object.Name, Change: "changed", Recorded: old.Definition, Current: object.Definition})
+		}
+	}
+	for _, object := range before {
+		changes = append(changes, SchemaChange{Kind: object.Kind, Name: object.Name, Change: "removed", Recorded: object.Definition})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// recordSchemaFingerprint stores the current fingerprint of schema. Unless
+// replace is set, an existing fingerprint is kept, so a run that changed
+// nothing does not bless changes made by hand since the last one.
+func recordSchemaFingerprint(parent context.Context, db *sql.DB, schema string, replace bool) error {
+	objects, err := schemaFingerprint(parent, db, schema)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(objects)
+	if err != nil {
+		return fmt.Errorf("encode schema fingerprint: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	table := qualifyIdent(schema, settingsTableName)
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(settingsTableDDL, table)); err != nil {
+		return fmt.Errorf("create settings table: %w", err)
+	}
+
+	upsert := fmt.Sprintf(`
+INSERT INTO %s (name, value) VALUES ($1, $2)
+ON CONFLICT (name) DO NOTHING`, table)
+	if replace {
+		upsert = fmt.Sprintf(`
+INSERT INTO %s (name, value) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()`, table)
+	}
+	if _, err := db.ExecContext(ctx, upsert, fingerprintSetting, string(data)); err != nil {
+		return fmt.Errorf("record schema fingerprint: %w", err)
+	}
+	return nil
+}
+
+// reportSchemaChanges passes the differences between the target schema and
+// its recorded fingerprint to Hooks.SchemaChanged. Nothing is reported when
+// no fingerprint has been recorded yet.
+func (m *Migrator) reportSchemaChanges(ctx context.Context) error {
+	if m.opts.Hooks.SchemaChanged == nil {
+		return nil
+	}
+
+	schema := m.opts.TargetSchema
+	recorded, _, found, err := loadSchemaFingerprint(ctx, m.db, schema)
+	if err != nil || !found {
+		return err
+	}
+	current, err := schemaFingerprint(ctx, m.db, schema)
+	if err != nil {
+		return err
+	}
+	if changes := diffSchemaObjects(recorded, current); len(changes) > 0 {
+		m.opts.Hooks.SchemaChanged(changes)
+	}
+	return nil
+}
+
+// loadSchemaFingerprint reads the recorded fingerprint, reporting whether
+// there is one.
+func loadSchemaFingerprint(parent context.Context, db *sql.DB, schema string) ([]SchemaObject, time.Time, bool, error) {
+	exists, err := tableExists(parent, db, schema, settingsTableName)
+	if err != nil || !exists {
+		return nil, time.Time{}, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	var (
+		value      string
+		recordedAt time.Time
+	)
+	query := fmt.Sprintf(`SELECT value, updated_at FROM %s WHERE name = $1`, qualifyIdent(schema, settingsTableName))
+	err = db.QueryRowContext(ctx, query, fingerprintSetting).Scan(&value, &recordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, false, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, fmt.Errorf("read schema fingerprint: %w", err)
+	}
+
+	var objects []SchemaObject
+	if err := json.Unmarshal([]byte(value), &objects); err != nil {
+		return nil, time.Time{}, false, fmt.Errorf("decode schema fingerprint: %w", err)
+	}
+	return objects, recordedAt, true, nil
+}
@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const migrationsTableDDL = `
+CREATE TABLE IF NOT EXISTS %s (
+	version VARCHAR(255) PRIMARY KEY,
+	filename VARCHAR(1024) NOT NULL,
+	applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	checksum VARCHAR(64),
+	execution_ms BIGINT,
+	applied_by VARCHAR(255),
+	tinytoe_version VARCHAR(64),
+	baselined BOOLEAN NOT NULL DEFAULT FALSE,
+	squashed_into VARCHAR(1024),
+	applied_order BIGINT
+)`
+
+// migrationsTableUpgrades bring tables created by earlier releases up to the
+// current definition. Each statement must be idempotent.
+var migrationsTableUpgrades = []string{
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS execution_ms BIGINT`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS applied_by VARCHAR(255)`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS tinytoe_version VARCHAR(64)`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS baselined BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS squashed_into VARCHAR(1024)`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS applied_order BIGINT`,
+}
+
+// prepare creates the target schema and bookkeeping tables when missing and,
+// for protected environments, records the protection marker.
+func (m *Migrator) prepare(ctx context.Context) error {
+	if err := ensureTargetSchema(ctx, m.db, m.opts.TargetSchema); err != nil {
+		return err
+	}
+	if err := ensureMigrationsTable(ctx, m.db, m.opts.TargetSchema); err != nil {
+		return err
+	}
+	if m.opts.Protected {
+		return markProtected(ctx, m.db, m.opts.TargetSchema)
+	}
+	return nil
+}
+
+func ensureTargetSchema(parent context.Context, db *sql.DB, schema string) error {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	stmt := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", quoteIdent(schema))
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("ensure target schema %q: %w", schema, err)
+	}
+	return nil
+}
+
+func ensureMigrationsTable(parent context.Context, db *sql.DB, schema string) error {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	table := qualifyIdent(schema, "tinytoe_migrations")
+	stmt := fmt.Sprintf(migrationsTableDDL, table)
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	for _, upgrade := range migrationsTableUpgrades {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(upgrade, table)); err != nil {
+			return fmt.Errorf("upgrade migrations table: %w", err)
+		}
+	}
+
+	stmt = fmt.Sprintf(repeatableTableDDL, qualifyIdent(schema, repeatableTableName))
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("create repeatable migrations table: %w", err)
+	}
+	return nil
+}
+
+func dropTargetSchema(parent context.Context, db *sql.DB, schema string) error {
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	defer cancel()
+
+	stmt := fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", quoteIdent(schema))
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("drop schema %q: %w", schema, err)
+	}
+	return nil
+}
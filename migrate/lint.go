@@ -0,0 +1,485 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint rule names, as used in LintOptions.Disabled and in
+// `-- tinytoe:lint-ignore=<rule>` comments.
+const (
+	// RuleVolatileDefault flags ADD COLUMN with a volatile DEFAULT (or a
+	// serial type), which rewrites the whole table under an exclusive lock.
+	RuleVolatileDefault = "volatile-default"
+	// RuleNonConcurrentIndex flags CREATE INDEX without CONCURRENTLY on a
+	// table the migration did not create, which blocks writes while it builds.
+	RuleNonConcurrentIndex = "non-concurrent-index"
+	// RuleAlterColumnType flags ALTER COLUMN ... TYPE, which usually rewrites
+	// the table and its indexes under an exclusive lock.
+	RuleAlterColumnType = "alter-column-type"
+	// RuleMissingIfNotExists flags CREATE TABLE, INDEX, SCHEMA, SEQUENCE or
+	// EXTENSION and ADD COLUMN without IF NOT EXISTS.
+	RuleMissingIfNotExists = "missing-if-not-exists"
+)
+
+// LintRules lists every lint rule name.
+var LintRules = []string{RuleVolatileDefault, RuleNonConcurrentIndex, RuleAlterColumnType, RuleMissingIfNotExists}
+
+const (
+	lintIgnoreDirective     = "lint-ignore"
+	lintIgnoreFileDirective = "lint-ignore-file"
+)
+
+// volatileFunctions are built-in functions whose use in a column default
+// forces a table rewrite when the column is added.
+var volatileFunctions = map[string]bool{
+	"RANDOM":             true,
+	"GEN_RANDOM_UUID":    true,
+	"UUID_GENERATE_V1":   true,
+	"UUID_GENERATE_V1MC": true,
+	"UUID_GENERATE_V4":   true,
+	"CLOCK_TIMESTAMP":    true,
+	"TIMEOFDAY":          true,
+	"NEXTVAL":            true,
+	"TXID_CURRENT":       true,
+}
+
+// LintOptions tune how Lint behaves.
+type LintOptions struct {
+	// All lints every migration file instead of only pending ones. It needs
+	// no database connection.
+	All bool
+	// Disabled names rules to skip.
+	Disabled []string
+}
+
+// LintViolation is a single rule violation.
+type LintViolation struct {
+	Migration Migration `json:"migration"`
+	// Line is the 1-based line of the offending statement or clause.
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// LintResult describes the outcome of Lint.
+type LintResult struct {
+	// Checked lists the migrations that were linted.
+	Checked []Migration
+	// Violations lists problems in file and line order.
+	Violations []LintViolation
+}
+
+// Lint checks migration files for DDL that is dangerous to run against a
+// live database. Only pending migrations are checked unless opts.All is set.
+// Violations are reported in the result, not as an error.
+func (m *Migrator) Lint(ctx context.Context, opts LintOptions) (*LintResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	disabled := make(map[string]bool, len(opts.Disabled))
+	for _, rule := range opts.Disabled {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if !isLintRule(rule) {
+			return nil, fmt.Errorf("unknown lint rule %q (available: %s)", rule, strings.Join(LintRules, ", "))
+		}
+		disabled[rule] = true
+	}
+
+	files, err := discoverMigrations(m.opts.FS, m.opts.DumpFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.All {
+		if err := m.ping(ctx); err != nil {
+			return nil, err
+		}
+		exists, err := migrationsTableExists(ctx, m.db, m.opts.TargetSchema)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			applied, err := loadAppliedMigrations(ctx, m.db, m.opts.TargetSchema)
+			if err != nil {
+				return nil, err
+			}
+			if applied, _, err = collapseSquashed(files, applied); err != nil {
+				return nil, err
+			}
+			files = pendingMigrations(m.matchFiles(files, applied), applied)
+		}
+	}
+
+	result := &LintResult{Checked: []Migration{}, Violations: []LintViolation{}}
+	for _, file := range files {
+		data, err := readMigration(file)
+		if err != nil {
+			return nil, err
+		}
+		// Squash snapshots are generated from the catalog and only ever run
+		// on empty databases.
+		if directives, err := parseDirectives(file.filename, data); err == nil && directives.squash {
+			continue
+		}
+		result.Checked = append(result.Checked, file.migration())
+		for _, violation := range lintSQL(string(data), disabled) {
+			violation.Migration = file.migration()
+			result.Violations = append(result.Violations, violation)
+		}
+	}
+	return result, nil
+}
+
+func isLintRule(name string) bool {
+	for _, rule := range LintRules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lintSQL applies every enabled rule to a script, honoring inline
+// suppressions. A `-- tinytoe:lint-ignore=<rule>[,<rule>]` comment covers the
+// statement on its line, or the next statement when it stands on its own
+// lines; `-- tinytoe:lint-ignore-file=<rule>` covers the whole file.
+func lintSQL(script string, disabled map[string]bool) []LintViolation {
+	statements, comments := tokenizeStatements(script)
+
+	fileIgnored := make(map[string]bool)
+	type ignore struct {
+		line  int
+		rules []string
+	}
+	var ignores []ignore
+	for _, comment := range comments {
+		name, rules, ok := lintIgnoreComment(comment.text)
+		if !ok {
+			continue
+		}
+		if name == lintIgnoreFileDirective {
+			for _, rule := range rules {
+				fileIgnored[rule] = true
+			}
+			continue
+		}
+		ignores = append(ignores, ignore{line: comment.line, rules: rules})
+	}
+
+	var violations []LintViolation
+	createdTables := make(map[string]bool)
+	previousEnd := 0
+	for _, stmt := range statements {
+		suppressed := make(map[string]bool)
+		for _, ig := range ignores {
+			onStatement := ig.line >= stmt.firstLine && ig.line <= stmt.lastLine
+			before := ig.line < stmt.firstLine && ig.line > previousEnd
+			if onStatement || before {
+				for _, rule := range ig.rules {
+					suppressed[rule] = true
+				}
+			}
+		}
+		previousEnd = stmt.lastLine
+
+		for _, violation := range lintStatement(stmt, createdTables) {
+			if disabled[violation.Rule] || fileIgnored[violation.Rule] || suppressed[violation.Rule] {
+				continue
+			}
+			violations = append(violations, violation)
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Line < violations[j].Line })
+	return violations
+}
+
+// lintIgnoreComment parses a `tinytoe:lint-ignore=` or
+// `tinytoe:lint-ignore-file=` comment.
+func lintIgnoreComment(text string) (string, []string, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, directivePrefix) {
+		return "", nil, false
+	}
+	name, value, _ := strings.Cut(strings.TrimPrefix(text, directivePrefix), "=")
+	name = strings.TrimSpace(name)
+	if name != lintIgnoreDirective && name != lintIgnoreFileDirective {
+		return "", nil, false
+	}
+	var rules []string
+	for _, rule := range strings.Split(value, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return name, rules, true
+}
+
+// lintStatement checks a single statement. createdTables collects tables
+// created earlier in the same file, which are new and empty, so indexing them
+// without CONCURRENTLY is harmless.
+func lintStatement(stmt tokenStatement, createdTables map[string]bool) []LintViolation {
+	tokens := stmt.tokens
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	switch tokens[0].upper {
+	case "CREATE":
+		return lintCreate(tokens, createdTables)
+	case "ALTER":
+		if len(tokens) > 1 && tokens[1].upper == "TABLE" {
+			return lintAlterTable(tokens)
+		}
+	}
+	return nil
+}
+
+func lintCreate(tokens []sqlToken, createdTables map[string]bool) []LintViolation {
+	i := 1
+	for i < len(tokens) && isWord(tokens[i], "UNIQUE", "UNLOGGED", "TEMP", "TEMPORARY", "GLOBAL", "LOCAL") {
+		i++
+	}
+	if i >= len(tokens) {
+		return nil
+	}
+	kind := tokens[i]
+	switch kind.upper {
+	case "TABLE", "INDEX", "SCHEMA", "SEQUENCE", "EXTENSION":
+	default:
+		return nil
+	}
+	i++
+
+	var violations []LintViolation
+	concurrently := false
+	if kind.upper == "INDEX" && i < len(tokens) && tokens[i].upper == "CONCURRENTLY" {
+		concurrently = true
+		i++
+	}
+	if hasWords(tokens, i, "IF", "NOT", "EXISTS") {
+		i += 3
+	} else {
+		violations = append(violations, LintViolation{
+			Line:    tokens[0].line,
+			Rule:    RuleMissingIfNotExists,
+			Message: fmt.Sprintf("CREATE %s without IF NOT EXISTS fails if the object already exists", kind.upper),
+		})
+	}
+
+	switch kind.upper {
+	case "TABLE":
+		if name, _ := qualifiedName(tokens, i); name != "" {
+			createdTables[name] = true
+		}
+	case "INDEX":
+		if concurrently {
+			break
+		}
+		on := indexOfWord(tokens, i, "ON")
+		if on < 0 {
+			break
+		}
+		j := on + 1
+		if j < len(tokens) && tokens[j].upper == "ONLY" {
+			j++
+		}
+		table, _ := qualifiedName(tokens, j)
+		if table == "" || createdTables[table] {
+			break
+		}
+		violations = append(violations, LintViolation{
+			Line:    kind.line,
+			Rule:    RuleNonConcurrentIndex,
+			Message: fmt.Sprintf("CREATE INDEX on %s blocks writes while the index builds; use CREATE INDEX CONCURRENTLY in a tinytoe:no-transaction migration", table),
+		})
+	}
+	return violations
+}
+
+func lintAlterTable(tokens []sqlToken) []LintViolation {
+	i := 2
+	if hasWords(tokens, i, "IF", "EXISTS") {
+		i += 2
+	}
+	if i < len(tokens) && tokens[i].upper == "ONLY" {
+		i++
+	}
+	table, next := qualifiedName(tokens, i)
+	if table == "" {
+		return nil
+	}
+
+	var violations []LintViolation
+	for _, action := range splitTopLevel(tokens[next:], ",") {
+		if len(action) == 0 {
+			continue
+		}
+		switch action[0].upper {
+		case "ADD":
+			violations = append(violations, lintAddColumn(table, action)...)
+		case "ALTER":
+			j := 1
+			if j < len(action) && action[j].upper == "COLUMN" {
+				j++
+			}
+			if j >= len(action) {
+				continue
+			}
+			column := action[j].text
+			j++
+			if hasWords(action, j, "SET", "DATA") {
+				j += 2
+			}
+			if j < len(action) && action[j].upper == "TYPE" {
+				violations = append(violations, LintViolation{
+					Line:    action[0].line,
+					Rule:    RuleAlterColumnType,
+					Message: fmt.Sprintf("changing the type of %s.%s usually rewrites the table and its indexes under an exclusive lock", table, column),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+func lintAddColumn(table string, action []sqlToken) []LintViolation {
+	j := 1
+	explicitColumn := j < len(action) && action[j].upper == "COLUMN"
+	if explicitColumn {
+		j++
+	} else if j < len(action) && isWord(action[j], "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "EXCLUDE") {
+		return nil
+	}
+
+	var violations []LintViolation
+	if hasWords(action, j, "IF", "NOT", "EXISTS") {
+		j += 3
+	} else {
+		violations = append(violations, LintViolation{
+			Line:    action[0].line,
+			Rule:    RuleMissingIfNotExists,
+			Message: fmt.Sprintf("ADD COLUMN on %s without IF NOT EXISTS fails if the column already exists", table),
+		})
+	}
+	if j >= len(action) {
+		return violations
+	}
+	column := action[j].text
+
+	if j+1 < len(action) && isWord(action[j+1], "SERIAL", "BIGSERIAL", "SMALLSERIAL", "SERIAL4", "SERIAL8", "SERIAL2") {
+		violations = append(violations, LintViolation{
+			Line:    action[j+1].line,
+			Rule:    RuleVolatileDefault,
+			Message: fmt.Sprintf("adding serial column %s.%s fills every existing row from a sequence, rewriting the table under an exclusive lock", table, column),
+		})
+		return violations
+	}
+
+	def := indexOfWord(action, j+1, "DEFAULT")
+	if def < 0 {
+		return violations
+	}
+	for k := def + 1; k+1 < len(action); k++ {
+		if isWord(action[k], "CHECK", "REFERENCES", "GENERATED", "CONSTRAINT") {
+			break
+		}
+		if !action[k].quoted && !action[k].literal && volatileFunctions[action[k].upper] && action[k+1].text == "(" {
+			violations = append(violations, LintViolation{
+				Line:    action[def].line,
+				Rule:    RuleVolatileDefault,
+				Message: fmt.Sprintf("DEFAULT %s() on new column %s.%s is volatile, so every existing row is rewritten under an exclusive lock; add the column without a default, backfill in batches, then set the default", strings.ToLower(action[k].text), table, column),
+			})
+			break
+		}
+	}
+	return violations
+}
+
+// qualifiedName reads a possibly schema-qualified name starting at i and
+// returns it unquoted and lower-cased (quoted parts keep their case), along
+// with the index of the following token.
+func qualifiedName(tokens []sqlToken, i int) (string, int) {
+	var parts []string
+	for i < len(tokens) {
+		token := tokens[i]
+		if token.literal || (!token.quoted && !isIdentByte(token.text[0])) {
+			break
+		}
+		name := token.text
+		if !token.quoted {
+			name = strings.ToLower(name)
+		}
+		parts = append(parts, name)
+		i++
+		if i < len(tokens) && tokens[i].text == "." {
+			i++
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "."), i
+}
+
+// splitTopLevel splits tokens on sep outside parentheses.
+func splitTopLevel(tokens []sqlToken, sep string) [][]sqlToken {
+	var parts [][]sqlToken
+	depth, start := 0, 0
+	for i, token := range tokens {
+		if token.quoted || token.literal {
+			continue
+		}
+		switch token.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tokens[start:])
+}
+
+func isWord(token sqlToken, words ...string) bool {
+	if token.quoted || token.literal {
+		return false
+	}
+	for _, word := range words {
+		if token.upper == word {
+			return true
+		}
+	}
+	return false
+}
+
+func hasWords(tokens []sqlToken, i int, words ...string) bool {
+	if i+len(words) > len(tokens) {
+		return false
+	}
+	for k, word := range words {
+		if !isWord(tokens[i+k], word) {
+			return false
+		}
+	}
+	return true
+}
+
+func indexOfWord(tokens []sqlToken, from int, word string) int {
+	for i := from; i < len(tokens); i++ {
+		if isWord(tokens[i], word) {
+			return i
+		}
+	}
+	return -1
+}
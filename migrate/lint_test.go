@@ -0,0 +1,75 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"tinytoe/migrate"
+)
+
+func TestMigratorLintReportsViolationsWithSuppressions(t *testing.T) {
+	db, err := sql.Open("pgx", "postgres://localhost/unused")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"20230101010101_create_widgets.sql": {Data: []byte(strings.Join([]string{
+			"CREATE TABLE IF NOT EXISTS widgets (id INT, name TEXT);",
+			"CREATE INDEX IF NOT EXISTS widgets_name_idx ON widgets (name);",
+		}, "\n"))},
+		"20230101010202_alter_users.sql": {Data: []byte(strings.Join([]string{
+			"-- tinytoe:lint-ignore-file=missing-if-not-exists",
+			"CREATE INDEX users_email_idx ON users (email);",
+			"ALTER TABLE users",
+			"  ADD COLUMN token UUID NOT NULL DEFAULT gen_random_uuid(),",
+			"  ADD COLUMN created_at TIMESTAMPTZ DEFAULT now();",
+			"ALTER TABLE users ALTER COLUMN age TYPE BIGINT; -- tinytoe:lint-ignore=alter-column-type",
+			"-- tinytoe:lint-ignore=non-concurrent-index",
+			"CREATE INDEX users_name_idx ON users (name);",
+			"ALTER TABLE users ADD COLUMN note TEXT DEFAULT 'random()';",
+		}, "\n"))},
+	}
+
+	migrator, err := migrate.New(migrate.Options{DB: db, FS: fsys})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	result, err := migrator.Lint(context.Background(), migrate.LintOptions{All: true})
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(result.Checked) != 2 {
+		t.Fatalf("expected both migrations to be checked, got %+v", result.Checked)
+	}
+
+	var got []string
+	for _, violation := range result.Violations {
+		got = append(got, fmt.Sprintf("%s:%d:%s", violation.Migration.Filename, violation.Line, violation.Rule))
+	}
+	want := []string{
+		"20230101010202_alter_users.sql:2:non-concurrent-index",
+		"20230101010202_alter_users.sql:4:volatile-default",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected violations:\n got %v\nwant %v", got, want)
+	}
+
+	result, err = migrator.Lint(context.Background(), migrate.LintOptions{All: true, Disabled: []string{migrate.RuleVolatileDefault}})
+	if err != nil {
+		t.Fatalf("Lint with disabled rule: %v", err)
+	}
+	if len(result.Violations) != 1 || result.Violations[0].Rule != migrate.RuleNonConcurrentIndex {
+		t.Fatalf("expected disabled rule to be skipped, got %+v", result.Violations)
+	}
+
+	if _, err := migrator.Lint(context.Background(), migrate.LintOptions{All: true, Disabled: []string{"no-such-rule"}}); err == nil {
+		t.Fatalf("expected unknown rule to be rejected")
+	}
+}
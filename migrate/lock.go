@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+const lockPollInterval = 500 * time.Millisecond
+
+// migrationLock is a session-level advisory lock held on a dedicated
+// connection for the duration of a run.
+type migrationLock struct {
+	conn   *sql.Conn
+	key    int64
+	schema string
+}
+
+// acquireMigrationLock takes the advisory lock guarding the target schema,
+// polling until it becomes available or the wait timeout elapses. A zero
+// timeout waits indefinitely. onWait, when set, is told about the holder once.
+func acquireMigrationLock(ctx context.Context, db *sql.DB, schema string, timeout time.Duration, onWait func(holder string)) (*migrationLock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reserve connection for migration lock: %w", err)
+	}
+
+	lock := &migrationLock{conn: conn, key: migrationLockKey(schema), schema: schema}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	warned := false
+	for {
+		acquired, err := lock.try(ctx)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if acquired {
+			return lock, nil
+		}
+
+		holder := lock.holder(ctx)
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			_ = conn.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for migration lock on schema %q held by %s", timeout, schema, holder)
+		}
+		if !warned {
+			if onWait != nil {
+				onWait(holder)
+			}
+			warned = true
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+			return nil, fmt.Errorf("wait for migration lock on schema %q: %w", schema, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (l *migrationLock) try(parent context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	var acquired bool
+	if err := l.conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("acquire migration lock on schema %q: %w", l.schema, err)
+	}
+	return acquired, nil
+}
+
+// holder describes the backend currently holding the lock for error messages.
+func (l *migrationLock) holder(parent context.Context) string {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	// Bigint advisory keys are split across classid (high) and objid (low).
+	query := `
+SELECT pid FROM pg_locks
+WHERE locktype = 'advisory' AND granted
+  AND classid = (($1::bigint >> 32) & 4294967295)::oid
+  AND objid = ($1::bigint & 4294967295)::oid
+  AND objsubid = 1
+LIMIT 1`
+	var pid int
+	if err := l.conn.QueryRowContext(ctx, query, l.key).Scan(&pid); err != nil {
+		return "another session"
+	}
+	return fmt.Sprintf("PID %d", pid)
+}
+
+// release unlocks and returns the dedicated connection to the pool. It uses a
+// fresh context so the lock is released even when the run was cancelled.
+func (l *migrationLock) release() error {
+	if l == nil || l.conn == nil {
+		return nil
+	}
+	defer l.conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		return fmt.Errorf("release migration lock on schema %q: %w", l.schema, err)
+	}
+	return nil
+}
+
+func migrationLockKey(schema string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("tinytoe:" + schema))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,259 @@
+// Package migrate applies Tiny Toe migrations from Go code. It is the engine
+// behind the tinytoe CLI and can be embedded in services that want to migrate
+// their database at startup without shelling out to the binary.
+//
+// A Migrator is built from Options around an existing *sql.DB or
+// *pgxpool.Pool. Its methods return structured results and never print;
+// progress is reported through the optional Hooks.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Options configure a Migrator. Exactly one of DB or Pool must be set.
+type Options struct {
+	// DB is an open database handle. The Migrator does not close it.
+	DB *sql.DB
+	// Pool is a pgx connection pool, used when DB is nil. The Migrator does
+	// not close it either.
+	Pool *pgxpool.Pool
+
+	// MigrationsDir holds the migration files. Defaults to "migrations".
+	MigrationsDir string
+	// FS, when set, supplies the migration files instead of MigrationsDir,
+	// with versioned files at its root and repeatable ones under repeatable/.
+	// Use fs.Sub to root an embed.FS at its migrations directory.
+	FS fs.FS
+	// DumpFile names a schema snapshot kept at the root of the migrations
+	// under a name other than SchemaDumpFile, so it is not mistaken for a
+	// migration. Files named SchemaDumpFile are always skipped.
+	DumpFile string
+	// TargetSchema is the schema migrations run in and where the bookkeeping
+	// tables live. Defaults to "public".
+	TargetSchema string
+
+	// ConnectTimeout is how long to keep retrying an unreachable database,
+	// with exponential backoff, before giving up with an *UnreachableError.
+	// Zero tries once.
+	ConnectTimeout time.Duration
+	// LockWaitTimeout limits how long to wait for the migration advisory lock.
+	// Zero waits indefinitely.
+	LockWaitTimeout time.Duration
+	// LockTimeout sets PostgreSQL's lock_timeout for each migration. Zero
+	// leaves the server default in place.
+	LockTimeout time.Duration
+	// StatementTimeout sets PostgreSQL's statement_timeout for each migration.
+	// Zero leaves the server default in place.
+	StatementTimeout time.Duration
+	// MigrationTimeout bounds each migration on the client side. Zero disables
+	// the limit.
+	MigrationTimeout time.Duration
+
+	// Protected marks the target schema as protected: Init and Up record a
+	// marker in the database, and DropAll and Reset refuse to run.
+	Protected bool
+	// AllowProtected lets DropAll and Reset drop a protected schema when it
+	// equals the name of the connected database.
+	AllowProtected string
+
+	// AllowOutOfOrder matches applied rows to files by version instead of by
+	// position, so pending migrations older than the latest applied one are
+	// applied rather than reported as drift. Hooks.OutOfOrder is called for
+	// each of them.
+	AllowOutOfOrder bool
+
+	// AppliedBy identifies the runner in the applied_by column. Defaults to
+	// DefaultAppliedBy().
+	AppliedBy string
+
+	// Hooks receive progress notifications while the Migrator runs.
+	Hooks Hooks
+}
+
+// Hooks are optional callbacks invoked while a Migrator runs. They are called
+// synchronously on the goroutine running the migration.
+type Hooks struct {
+	// ConnectRetry is called after each failed connection attempt that will be
+	// retried, with the attempt number, the delay before the next attempt and
+	// the error.
+	ConnectRetry func(attempt int, delay time.Duration, err error)
+	// LockWait is called once when another session holds the migration lock,
+	// describing the holder (e.g. "PID 1234").
+	LockWait func(holder string)
+	// Initialized is called when Up creates the bookkeeping tables because the
+	// target schema had never been migrated.
+	Initialized func()
+	// OutOfOrder is called before applying a migration older than the latest
+	// applied one, with the filename of that latest migration. It is only
+	// called when Options.AllowOutOfOrder is set.
+	OutOfOrder func(migration Migration, latest string)
+	// Applied is called after each migration is applied and recorded.
+	Applied func(migration Migration)
+	// SchemaChanged is called by Up, before applying anything, when the
+	// target schema differs from the fingerprint recorded by the last run,
+	// i.e. it was changed outside Tiny Toe. The fingerprint recorded once the
+	// run succeeds includes those changes, so this is the last chance to see
+	// them.
+	SchemaChanged func(changes []SchemaChange)
+}
+
+// Migration identifies a migration file.
+type Migration struct {
+	// Version is the timestamp prefix; empty for repeatable migrations.
+	Version string `json:"version,omitempty"`
+	// Filename is the path relative to the migrations directory.
+	Filename string `json:"filename"`
+	// Repeatable marks files from the repeatable/ subdirectory.
+	Repeatable bool `json:"repeatable,omitempty"`
+}
+
+// Migrator applies migrations to a single target schema.
+type Migrator struct {
+	db     *sql.DB
+	ownsDB bool
+	opts   Options
+	// eachStatement replays a failed transactional migration one statement
+	// at a time, so the error names its statement even when PostgreSQL
+	// reports no position. Scratch schemas use it; regular runs do not pay
+	// for the second attempt.
+	eachStatement bool
+}
+
+// New builds a Migrator from opts, applying defaults for unset fields.
+func New(opts Options) (*Migrator, error) {
+	if opts.DB != nil && opts.Pool != nil {
+		return nil, fmt.Errorf("migrate: set either DB or Pool, not both")
+	}
+	if opts.DB == nil && opts.Pool == nil {
+		return nil, fmt.Errorf("migrate: a DB or Pool is required")
+	}
+
+	opts.MigrationsDir = strings.TrimSpace(opts.MigrationsDir)
+	if opts.MigrationsDir == "" {
+		opts.MigrationsDir = "migrations"
+	}
+	opts.TargetSchema = strings.TrimSpace(opts.TargetSchema)
+	if opts.TargetSchema == "" {
+		opts.TargetSchema = "public"
+	}
+	if opts.FS == nil {
+		opts.FS = os.DirFS(opts.MigrationsDir)
+	}
+	if opts.AppliedBy == "" {
+		opts.AppliedBy = DefaultAppliedBy()
+	}
+
+	m := &Migrator{db: opts.DB, opts: opts}
+	if m.db == nil {
+		m.db = stdlib.OpenDBFromPool(opts.Pool)
+		m.ownsDB = true
+	}
+	return m, nil
+}
+
+// Close releases the database/sql adapter created for a Pool. It never closes
+// a caller-supplied DB or Pool.
+func (m *Migrator) Close() error {
+	if m.ownsDB {
+		return m.db.Close()
+	}
+	return nil
+}
+
+// Init creates the target schema and the bookkeeping tables when missing, and
+// records the protection marker when Options.Protected is set. It is
+// idempotent.
+func (m *Migrator) Init(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := m.ping(ctx); err != nil {
+		return err
+	}
+	return m.prepare(ctx)
+}
+
+// DropAll drops the target schema and everything in it. It refuses to drop a
+// protected schema; see CheckDrop.
+func (m *Migrator) DropAll(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, err := m.CheckDrop(ctx); err != nil {
+		return err
+	}
+	return dropTargetSchema(ctx, m.db, m.opts.TargetSchema)
+}
+
+// Reset drops the target schema, recreates the bookkeeping tables and applies
+// every migration from scratch. A protection marker found before the drop is
+// recorded again in the recreated schema.
+func (m *Migrator) Reset(ctx context.Context) (*UpResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Refuse to drop anything when the migrations cannot be read back.
+	if _, err := discoverMigrations(m.opts.FS, m.opts.DumpFile); err != nil {
+		return nil, err
+	}
+	marked, err := m.CheckDrop(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := dropTargetSchema(ctx, m.db, m.opts.TargetSchema); err != nil {
+		return nil, err
+	}
+	if err := m.Init(ctx); err != nil {
+		return nil, err
+	}
+	if marked && !m.opts.Protected {
+		if err := markProtected(ctx, m.db, m.opts.TargetSchema); err != nil {
+			return nil, err
+		}
+	}
+	return m.Up(ctx, UpOptions{})
+}
+
+// DefaultAppliedBy describes the current runner as user@host, falling back to
+// whichever half is known, or "unknown".
+func DefaultAppliedBy() string {
+	username := strings.TrimSpace(os.Getenv("USER"))
+	if username == "" {
+		username = strings.TrimSpace(os.Getenv("USERNAME"))
+	}
+	if username == "" {
+		if current, err := user.Current(); err == nil && current.Username != "" {
+			username = current.Username
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+
+	username = strings.TrimSpace(username)
+	hostname = strings.TrimSpace(hostname)
+
+	switch {
+	case username != "" && hostname != "":
+		return fmt.Sprintf("%s@%s", username, hostname)
+	case username != "":
+		return username
+	case hostname != "":
+		return hostname
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,303 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"tinytoe/migrate"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestNewRequiresExactlyOneConnection(t *testing.T) {
+	if _, err := migrate.New(migrate.Options{}); err == nil {
+		t.Fatalf("expected error when neither DB nor Pool is set")
+	}
+
+	db, err := sql.Open("pgx", "postgres://localhost/unused")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost/unused")
+	if err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	defer pool.Close()
+
+	if _, err := migrate.New(migrate.Options{DB: db, Pool: pool}); err == nil {
+		t.Fatalf("expected error when both DB and Pool are set")
+	}
+}
+
+func TestMigratorRetriesUnreachableDatabase(t *testing.T) {
+	// Nothing listens on port 1, so every attempt is refused quickly.
+	db, err := sql.Open("pgx", "postgres://tinytoe@127.0.0.1:1/unused?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	retries := 0
+	migrator, err := migrate.New(migrate.Options{
+		DB:             db,
+		FS:             fstest.MapFS{},
+		ConnectTimeout: time.Second,
+		Hooks: migrate.Hooks{
+			ConnectRetry: func(attempt int, delay time.Duration, err error) {
+				retries++
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = migrator.Init(context.Background())
+	var unreachable *migrate.UnreachableError
+	if !errors.As(err, &unreachable) {
+		t.Fatalf("expected UnreachableError, got %v", err)
+	}
+	if unreachable.Attempts < 2 || retries != unreachable.Attempts-1 {
+		t.Fatalf("expected retries before giving up, got %d attempts and %d retry hooks", unreachable.Attempts, retries)
+	}
+}
+
+func TestMigratorDoesNotRetryAuthenticationFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	// A stand-in server that rejects every login the way PostgreSQL does for a
+	// wrong password.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			backend := pgproto3.NewBackend(conn, conn)
+			if _, err := backend.ReceiveStartupMessage(); err == nil {
+				backend.Send(&pgproto3.ErrorResponse{
+					Severity: "FATAL",
+					Code:     "28P01",
+					Message:  `password authentication failed for user "tinytoe"`,
+				})
+				_ = backend.Flush()
+			}
+			conn.Close()
+		}
+	}()
+
+	db, err := sql.Open("pgx", fmt.Sprintf("postgres://tinytoe@%s/unused?sslmode=disable&connect_timeout=1", listener.Addr()))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	retries := 0
+	migrator, err := migrate.New(migrate.Options{
+		DB:             db,
+		FS:             fstest.MapFS{},
+		ConnectTimeout: time.Minute,
+		Hooks: migrate.Hooks{
+			ConnectRetry: func(attempt int, delay time.Duration, err error) {
+				retries++
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	err = migrator.Init(context.Background())
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Fatalf("expected the authentication error, got %v", err)
+	}
+	var unreachable *migrate.UnreachableError
+	if errors.As(err, &unreachable) {
+		t.Fatalf("expected a reachable database refusing the login not to be reported as unreachable, got %v", err)
+	}
+	if retries != 0 {
+		t.Fatalf("expected no retries, got %d", retries)
+	}
+}
+
+func TestMigratorUpStatusBaselineAndReset(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_migrate_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("create pool: %v", err)
+	}
+	defer pool.Close()
+
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, schema))
+	})
+
+	migrationsDir := t.TempDir()
+	files := map[string]string{
+		"20230101010101_create_widgets.sql": "CREATE TABLE widgets (id INT PRIMARY KEY);\n",
+		"20230101010202_seed_widgets.sql":   "INSERT INTO widgets (id) VALUES (1), (2);\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write migration %s: %v", name, err)
+		}
+	}
+
+	var applied []string
+	migrator, err := migrate.New(migrate.Options{
+		Pool:          pool,
+		MigrationsDir: migrationsDir,
+		TargetSchema:  schema,
+		Hooks: migrate.Hooks{
+			Applied: func(migration migrate.Migration) {
+				applied = append(applied, migration.Filename)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer migrator.Close()
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status before up: %v", err)
+	}
+	if status.Initialized || status.Pending != 2 {
+		t.Fatalf("expected uninitialized schema with 2 pending migrations, got %+v", status)
+	}
+
+	result, err := migrator.Up(ctx, migrate.UpOptions{Steps: 1})
+	if err != nil {
+		t.Fatalf("Up with steps: %v", err)
+	}
+	if len(result.Applied) != 1 || result.Applied[0].Version != "20230101010101" || result.Remaining != 1 {
+		t.Fatalf("unexpected step result: %+v", result)
+	}
+
+	result, err = migrator.Up(ctx, migrate.UpOptions{})
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(result.Applied) != 1 || result.Applied[0].Filename != "20230101010202_seed_widgets.sql" {
+		t.Fatalf("unexpected up result: %+v", result)
+	}
+	if len(applied) != 2 {
+		t.Fatalf("expected Applied hook for each migration, got %v", applied)
+	}
+
+	status, err = migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status after up: %v", err)
+	}
+	if status.Drift != nil || status.Pending != 0 || status.Applied != 2 {
+		t.Fatalf("expected clean status, got %+v", status)
+	}
+	for _, migration := range status.Migrations {
+		if migration.State != migrate.StateApplied || migration.AppliedAt.IsZero() {
+			t.Fatalf("expected applied state with timestamp, got %+v", migration)
+		}
+	}
+
+	if _, err := migrator.Baseline(ctx, "20230101010202", migrate.BaselineOptions{}); err == nil {
+		t.Fatalf("expected baseline to refuse a non-empty history")
+	}
+	baseline, err := migrator.Baseline(ctx, "20230101010202", migrate.BaselineOptions{AllowExisting: true})
+	if err != nil {
+		t.Fatalf("Baseline allowing existing history: %v", err)
+	}
+	if len(baseline.Baselined) != 0 || len(baseline.Skipped) != 2 {
+		t.Fatalf("expected the baseline to skip recorded migrations, got %+v", baseline)
+	}
+
+	result, err = migrator.Reset(ctx)
+	if err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if len(result.Applied) != 2 {
+		t.Fatalf("expected reset to reapply 2 migrations, got %+v", result)
+	}
+
+	var count int
+	if err := pool.QueryRow(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM "%s".widgets`, schema)).Scan(&count); err != nil {
+		t.Fatalf("count widgets: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 widgets after reset, got %d", count)
+	}
+}
+
+func TestMigratorReadsMigrationsFromFS(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	schema := fmt.Sprintf("tt_migrate_fs_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	t.Cleanup(func() {
+		_, _ = db.ExecContext(context.Background(), fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, schema))
+	})
+
+	fsys := fstest.MapFS{
+		"20230101010101_create_widgets.sql": {Data: []byte("CREATE TABLE widgets (id INT PRIMARY KEY);\n")},
+		"repeatable/widget_ids.sql":         {Data: []byte("CREATE OR REPLACE VIEW widget_ids AS SELECT id FROM widgets;\n")},
+		"README.md":                         {Data: []byte("not a migration\n")},
+	}
+
+	migrator, err := migrate.New(migrate.Options{DB: db, FS: fsys, TargetSchema: schema})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	result, err := migrator.Up(ctx, migrate.UpOptions{})
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(result.Applied) != 2 || !result.Applied[1].Repeatable {
+		t.Fatalf("expected versioned and repeatable migrations to apply from the FS, got %+v", result)
+	}
+
+	fsys["20230101010101_create_widgets.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE widgets (id BIGINT PRIMARY KEY);\n")}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Drift == nil || !strings.Contains(status.Drift.Error(), "modified after it was applied") {
+		t.Fatalf("expected checksum drift against the FS contents, got %v", status.Drift)
+	}
+}
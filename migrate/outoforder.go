@@ -0,0 +1,68 @@
+package migrate
+
+import "io/fs"
+
+// matchFiles lines files up with the applied rows for the positional checks
+// in detectDrift, statusEntries, backfillChecksums and pendingMigrations.
+// Without Options.AllowOutOfOrder files are already in the right order. With
+// it, applied files come first, matched to the applied rows by version, and
+// the pending ones follow in timestamp order, so a file older than the latest
+// applied migration is pending rather than drift.
+func (m *Migrator) matchFiles(files []migrationFile, applied []appliedMigration) []migrationFile {
+	if !m.opts.AllowOutOfOrder {
+		return files
+	}
+	return matchFilesByVersion(m.opts.FS, files, applied)
+}
+
+// matchFilesByVersion reorders files to follow the applied rows. A row whose
+// file is gone gets a placeholder at the recorded filename, which the drift
+// checks then report as missing.
+func matchFilesByVersion(fsys fs.FS, files []migrationFile, applied []appliedMigration) []migrationFile {
+	byVersion := make(map[string]migrationFile, len(files))
+	for _, file := range files {
+		byVersion[file.version] = file
+	}
+
+	matched := make([]migrationFile, 0, len(files))
+	seen := make(map[string]bool, len(applied))
+	for _, row := range applied {
+		file, ok := byVersion[row.version]
+		if !ok {
+			file = migrationFile{version: row.version, filename: row.filename, fsys: fsys, path: row.filename}
+		}
+		matched = append(matched, file)
+		seen[row.version] = true
+	}
+	for _, file := range files {
+		if !seen[file.version] {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// isOutOfOrder reports whether a pending file is older than the latest
+// applied migration. Applied rows are sorted by version.
+func isOutOfOrder(file migrationFile, applied []appliedMigration) bool {
+	return !file.repeatable && len(applied) > 0 && file.version < applied[len(applied)-1].version
+}
+
+// appliedOutOfOrder reports, for each applied row, whether it was applied
+// after a row with a newer version, judging by applied_order. Rows recorded
+// before applied_order existed are never flagged.
+func appliedOutOfOrder(applied []appliedMigration) []bool {
+	flagged := make([]bool, len(applied))
+	var earliest int64 // lowest applied_order among newer versions
+	for i := len(applied) - 1; i >= 0; i-- {
+		order := applied[i].appliedOrder
+		if order == 0 {
+			continue
+		}
+		flagged[i] = earliest != 0 && earliest < order
+		if earliest == 0 || order < earliest {
+			earliest = order
+		}
+	}
+	return flagged
+}
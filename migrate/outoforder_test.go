@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testFiles(versions ...string) []migrationFile {
+	files := make([]migrationFile, 0, len(versions))
+	for _, version := range versions {
+		files = append(files, migrationFile{version: version, filename: version + "_m.sql", path: version + "_m.sql"})
+	}
+	return files
+}
+
+func testApplied(versions ...string) []appliedMigration {
+	applied := make([]appliedMigration, 0, len(versions))
+	for _, version := range versions {
+		applied = append(applied, appliedMigration{version: version, filename: version + "_m.sql"})
+	}
+	return applied
+}
+
+func filenames(files []migrationFile) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.filename)
+	}
+	return names
+}
+
+func TestMatchFilesByVersion(t *testing.T) {
+	fsys := fstest.MapFS{}
+	files := testFiles("20230101000001", "20230101000002", "20230101000003", "20230101000004")
+	for i := range files {
+		fsys[files[i].path] = &fstest.MapFile{Data: []byte("SELECT 1;\n")}
+		files[i].fsys = fsys
+	}
+	applied := testApplied("20230101000001", "20230101000003")
+	// The file behind this row was deleted.
+	applied = append(applied, appliedMigration{version: "20230101000009", filename: "20230101000009_gone.sql"})
+
+	matched := matchFilesByVersion(fsys, files, applied)
+
+	want := []string{
+		"20230101000001_m.sql",
+		"20230101000003_m.sql",
+		"20230101000009_gone.sql",
+		"20230101000002_m.sql",
+		"20230101000004_m.sql",
+	}
+	if got := filenames(matched); !reflect.DeepEqual(got, want) {
+		t.Fatalf("matchFilesByVersion() = %q, want %q", got, want)
+	}
+
+	placeholder := matched[2]
+	if placeholder.version != "20230101000009" || placeholder.path != "20230101000009_gone.sql" || !reflect.DeepEqual(placeholder.fsys, fsys) {
+		t.Fatalf("expected a placeholder at the recorded filename, got %#v", placeholder)
+	}
+	if err := detectDrift(matched, applied); err == nil || !strings.Contains(err.Error(), "20230101000009_gone.sql") {
+		t.Fatalf("expected the missing file to be reported as drift, got %v", err)
+	}
+}
+
+func TestIsOutOfOrder(t *testing.T) {
+	applied := testApplied("20230101000001", "20230101000003")
+	tests := []struct {
+		name    string
+		file    migrationFile
+		applied []appliedMigration
+		want    bool
+	}{
+		{name: "nothing applied", file: testFiles("20230101000002")[0], want: false},
+		{name: "older than the latest applied", file: testFiles("20230101000002")[0], applied: applied, want: true},
+		{name: "newer than the latest applied", file: testFiles("20230101000004")[0], applied: applied, want: false},
+		{name: "repeatable", file: migrationFile{filename: "repeatable/views.sql", repeatable: true}, applied: applied, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOutOfOrder(tt.file, tt.applied); got != tt.want {
+				t.Fatalf("isOutOfOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppliedOutOfOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		orders []int64
+		want   []bool
+	}{
+		{name: "in order", orders: []int64{1, 2, 3}, want: []bool{false, false, false}},
+		{name: "older version applied last", orders: []int64{1, 3, 2}, want: []bool{false, true, false}},
+		{name: "rows without applied_order", orders: []int64{0, 0, 1, 3, 2}, want: []bool{false, false, false, true, false}},
+		{name: "unordered row between ordered ones", orders: []int64{2, 0, 1}, want: []bool{true, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := make([]appliedMigration, len(tt.orders))
+			for i, order := range tt.orders {
+				applied[i] = appliedMigration{appliedOrder: order}
+			}
+			if got := appliedOutOfOrder(applied); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("appliedOutOfOrder(%v) = %v, want %v", tt.orders, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitPending(t *testing.T) {
+	files := testFiles("20230101000001", "20230101000002", "20230101000003", "20230101000004")
+	applied := testApplied("20230101000001", "20230101000003")
+	// 20230101000002 is pending out of order.
+	pending := matchFilesByVersion(nil, files, applied)[len(applied):]
+
+	tests := []struct {
+		name    string
+		opts    UpOptions
+		want    []string
+		wantErr string
+	}{
+		{name: "no limit", want: []string{"20230101000002_m.sql", "20230101000004_m.sql"}},
+		{name: "steps", opts: UpOptions{Steps: 1}, want: []string{"20230101000002_m.sql"}},
+		{name: "more steps than pending", opts: UpOptions{Steps: 5}, want: []string{"20230101000002_m.sql", "20230101000004_m.sql"}},
+		{name: "pending target older than the latest applied", opts: UpOptions{Target: "20230101000002"}, want: []string{"20230101000002_m.sql"}},
+		{name: "target is the latest applied", opts: UpOptions{Target: "20230101000003"}, want: []string{"20230101000002_m.sql"}},
+		{name: "applied target older than the latest applied", opts: UpOptions{Target: "20230101000001"}, wantErr: "behind the applied state"},
+		{name: "unknown target", opts: UpOptions{Target: "20230101000005"}, wantErr: "does not match any migration"},
+		{name: "target and steps", opts: UpOptions{Target: "20230101000004", Steps: 1}, wantErr: "cannot be combined"},
+		{name: "negative steps", opts: UpOptions{Steps: -1}, wantErr: "positive number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := limitPending(files, applied, pending, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("limitPending: %v", err)
+			}
+			if names := filenames(got); !reflect.DeepEqual(names, tt.want) {
+				t.Fatalf("limitPending() = %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const settingsTableName = "tinytoe_settings"
+
+const settingsTableDDL = `
+CREATE TABLE IF NOT EXISTS %s (
+	name VARCHAR(255) PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+)`
+
+// protectedSetting is the tinytoe_settings row marking the target schema as
+// protected. It is only ever added by Tiny Toe; removing it is a manual step.
+const protectedSetting = "protected"
+
+// markProtected records the protection marker in the target schema so that
+// runners without the protected config setting still refuse to drop it.
+func markProtected(parent context.Context, db *sql.DB, schema string) error {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	table := qualifyIdent(schema, settingsTableName)
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(settingsTableDDL, table)); err != nil {
+		return fmt.Errorf("create settings table: %w", err)
+	}
+
+	upsert := fmt.Sprintf(`
+INSERT INTO %s (name, value) VALUES ($1, 'true')
+ON CONFLICT (name) DO NOTHING`, table)
+	if _, err := db.ExecContext(ctx, upsert, protectedSetting); err != nil {
+		return fmt.Errorf("record protection marker: %w", err)
+	}
+	return nil
+}
+
+func hasProtectionMarker(parent context.Context, db *sql.DB, schema string) (bool, error) {
+	exists, err := tableExists(parent, db, schema, settingsTableName)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE name = $1 AND value = 'true'`, qualifyIdent(schema, settingsTableName))
+	var count int
+	if err := db.QueryRowContext(ctx, query, protectedSetting).Scan(&count); err != nil {
+		return false, fmt.Errorf("read protection marker: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CheckDrop reports whether DropAll and Reset may drop the target schema. It
+// returns an error when the schema is protected, either through
+// Options.Protected or a marker stored in the database, and
+// Options.AllowProtected does not name the connected database. The boolean
+// reports whether the marker was present, so a caller that goes on to drop
+// the schema can record it again once the schema is recreated.
+func (m *Migrator) CheckDrop(ctx context.Context) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := m.ping(ctx); err != nil {
+		return false, err
+	}
+
+	schema := m.opts.TargetSchema
+	marked, err := hasProtectionMarker(ctx, m.db, schema)
+	if err != nil {
+		return false, err
+	}
+	source := "the configuration"
+	if !m.opts.Protected {
+		if !marked {
+			return false, nil
+		}
+		source = "a marker in the database"
+	}
+
+	database, err := currentDatabase(ctx, m.db)
+	if err != nil {
+		return marked, err
+	}
+	if m.opts.AllowProtected == database {
+		return marked, nil
+	}
+	return marked, fmt.Errorf("schema %q in database %q is protected by %s; dropall and reset refuse to run regardless of --force unless --allow-protected=%s names the database", schema, database, source, database)
+}
+
+func currentDatabase(parent context.Context, db *sql.DB) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	var name string
+	if err := db.QueryRowContext(ctx, `SELECT current_database()`).Scan(&name); err != nil {
+		return "", fmt.Errorf("read current database: %w", err)
+	}
+	return name, nil
+}
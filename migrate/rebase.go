@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// versionLayout formats migration versions, which are UTC timestamps.
+const versionLayout = "20060102150405"
+
+// RebaseOptions tune how Rebase selects migrations.
+type RebaseOptions struct {
+	// After, when set, selects the migrations newer than this version instead
+	// of those missing from the target database, which is then not consulted.
+	After string
+}
+
+// RebaseResult describes the new versions planned by Rebase.
+type RebaseResult struct {
+	// Latest is the version the rebased migrations must follow: the latest
+	// applied migration, or RebaseOptions.After.
+	Latest string
+	// Renames lists the rebased migrations, oldest first.
+	Renames []RebaseRename
+}
+
+// RebaseRename pairs a migration with its new version and filename.
+type RebaseRename struct {
+	From Migration `json:"from"`
+	To   Migration `json:"to"`
+}
+
+// Rebase plans fresh versions for the migrations not yet recorded in the
+// target database, so a branch whose migrations fell behind ones applied
+// elsewhere can move them to the end. The new versions start at the current
+// time, or one second after the latest applied migration if that is later,
+// and are one second apart, keeping the migrations' relative order. Rebase
+// only plans; renaming the files and rewriting their headers is up to the
+// caller.
+func (m *Migrator) Rebase(ctx context.Context, opts RebaseOptions) (*RebaseResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	files, err := discoverMigrations(m.opts.FS, m.opts.DumpFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RebaseResult{Renames: []RebaseRename{}}
+	var selected []migrationFile
+	if after := strings.TrimSpace(opts.After); after != "" {
+		if _, err := time.Parse(versionLayout, after); err != nil || !isDigits(after) {
+			return nil, fmt.Errorf("invalid rebase version %q: expected a 14-digit timestamp", after)
+		}
+		result.Latest = after
+		for _, file := range files {
+			if file.version > after {
+				selected = append(selected, file)
+			}
+		}
+	} else {
+		result.Latest, selected, err = m.unappliedMigrations(ctx, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	next := time.Now().UTC().Truncate(time.Second)
+	if latest, err := time.Parse(versionLayout, result.Latest); err == nil && !next.After(latest) {
+		next = latest.Add(time.Second)
+	}
+	for _, file := range selected {
+		version := next.Format(versionLayout)
+		result.Renames = append(result.Renames, RebaseRename{
+			From: file.migration(),
+			To:   Migration{Version: version, Filename: version + file.filename[len(file.version):]},
+		})
+		next = next.Add(time.Second)
+	}
+	return result, nil
+}
+
+// unappliedMigrations returns the latest applied version and the files the
+// target database has not recorded, matching rows by version so files older
+// than the latest applied one are included. Drift in the applied files is an
+// error, as is a database with nothing applied, where every file would move.
+func (m *Migrator) unappliedMigrations(ctx context.Context, files []migrationFile) (string, []migrationFile, error) {
+	if err := m.ping(ctx); err != nil {
+		return "", nil, err
+	}
+	exists, err := migrationsTableExists(ctx, m.db, m.opts.TargetSchema)
+	if err != nil {
+		return "", nil, err
+	}
+	var applied []appliedMigration
+	if exists {
+		if applied, err = loadAppliedMigrations(ctx, m.db, m.opts.TargetSchema); err != nil {
+			return "", nil, err
+		}
+	}
+	if len(applied) == 0 {
+		return "", nil, fmt.Errorf("schema %q has no applied migrations to rebase onto; pass --after <version> to choose the migrations to rebase", m.opts.TargetSchema)
+	}
+
+	if applied, _, err = collapseSquashed(files, applied); err != nil {
+		return "", nil, err
+	}
+	files = matchFilesByVersion(m.opts.FS, files, applied)
+	if err := detectDrift(files, applied); err != nil {
+		return "", nil, err
+	}
+	return applied[len(applied)-1].version, pendingMigrations(files, applied), nil
+}
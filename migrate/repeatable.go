@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// repeatableDir is the subdirectory of the migrations directory holding
+// repeatable migrations (views, functions, triggers) that are re-applied
+// whenever their contents change.
+const repeatableDir = "repeatable"
+
+const repeatableTableName = "tinytoe_repeatable_migrations"
+
+const repeatableTableDDL = `
+CREATE TABLE IF NOT EXISTS %s (
+	name VARCHAR(1024) PRIMARY KEY,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	execution_ms BIGINT,
+	applied_by VARCHAR(255),
+	tinytoe_version VARCHAR(64)
+)`
+
+type appliedRepeatable struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// discoverRepeatables lists .sql files in the repeatable subdirectory in
+// filename order. A missing subdirectory simply means there are none.
+func discoverRepeatables(fsys fs.FS) ([]migrationFile, error) {
+	entries, err := fs.ReadDir(fsys, repeatableDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read repeatable migrations directory: %w", err)
+	}
+
+	var files []migrationFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		name := path.Join(repeatableDir, entry.Name())
+		files = append(files, migrationFile{
+			filename:   name,
+			fsys:       fsys,
+			path:       name,
+			repeatable: true,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].filename < files[j].filename
+	})
+	return files, nil
+}
+
+func loadAppliedRepeatables(parent context.Context, db *sql.DB, schema string) (map[string]appliedRepeatable, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	exists, err := tableExists(ctx, db, schema, repeatableTableName)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[string]appliedRepeatable)
+	if !exists {
+		return applied, nil
+	}
+
+	query := fmt.Sprintf(`SELECT name, checksum, applied_at FROM %s`, qualifyIdent(schema, repeatableTableName))
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("load applied repeatable migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row appliedRepeatable
+		if err := rows.Scan(&row.name, &row.checksum, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("scan applied repeatable migration: %w", err)
+		}
+		applied[row.name] = row
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate applied repeatable migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// pendingRepeatables returns the repeatable migrations that have never been
+// applied or whose contents changed since they last ran.
+func pendingRepeatables(files []migrationFile, applied map[string]appliedRepeatable) ([]migrationFile, error) {
+	pending := make([]migrationFile, 0)
+	for _, file := range files {
+		sum, err := fileChecksum(file)
+		if err != nil {
+			return nil, err
+		}
+		if row, ok := applied[file.filename]; ok && row.checksum == sum {
+			continue
+		}
+		pending = append(pending, file)
+	}
+	return pending, nil
+}
+
+func recordRepeatable(ctx context.Context, exec execer, schema string, file migrationFile, data []byte, elapsed time.Duration, runner, toolVersion string) error {
+	upsert := fmt.Sprintf(`
+INSERT INTO %s (name, checksum, applied_at, execution_ms, applied_by, tinytoe_version)
+VALUES ($1, $2, NOW(), $3, $4, $5)
+ON CONFLICT (name) DO UPDATE SET
+	checksum = EXCLUDED.checksum,
+	applied_at = EXCLUDED.applied_at,
+	execution_ms = EXCLUDED.execution_ms,
+	applied_by = EXCLUDED.applied_by,
+	tinytoe_version = EXCLUDED.tinytoe_version`, qualifyIdent(schema, repeatableTableName))
+	if _, err := exec.ExecContext(ctx, upsert, file.filename, checksum(data), elapsed.Milliseconds(), runner, toolVersion); err != nil {
+		return fmt.Errorf("record repeatable migration %s: %w", file.filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package migrate
+
+import "strings"
+
+// sqlToken is a significant token of a SQL script. Words are upper-cased in
+// upper; string literals and dollar-quoted bodies are reduced to a single
+// opaque token so their contents never match a keyword.
+type sqlToken struct {
+	text  string
+	upper string
+	line  int
+	// quoted marks double-quoted identifiers; literal marks strings and
+	// dollar-quoted bodies.
+	quoted  bool
+	literal bool
+}
+
+// sqlComment is a line (--) or block (/* */) comment with the line it starts on.
+type sqlComment struct {
+	text string
+	line int
+}
+
+// tokenStatement is a statement as a token list, without the terminating
+// semicolon, along with the lines it spans.
+type tokenStatement struct {
+	tokens    []sqlToken
+	firstLine int
+	lastLine  int
+}
+
+// tokenizeStatements splits a script into token lists on top-level
+// semicolons and collects its comments. It follows the quoting and BEGIN
+// ATOMIC rules of splitStatements.
+func tokenizeStatements(script string) ([]tokenStatement, []sqlComment) {
+	var (
+		statements []tokenStatement
+		comments   []sqlComment
+		current    tokenStatement
+	)
+
+	line := 1
+	var blocks atomicBlocks
+	emit := func(token sqlToken) {
+		if len(current.tokens) == 0 {
+			current.firstLine = token.line
+		}
+		token.upper = strings.ToUpper(token.text)
+		current.tokens = append(current.tokens, token)
+		current.lastLine = line
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		start, startLine := i, line
+		switch {
+		case c == '\n':
+			line++
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '-' && i+1 < len(script) && script[i+1] == '-':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			comments = append(comments, sqlComment{text: script[start+2 : i], line: startLine})
+			if i < len(script) {
+				line++
+			}
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			depth := 0
+			for i < len(script) {
+				if script[i] == '\n' {
+					line++
+				}
+				if script[i] == '/' && i+1 < len(script) && script[i+1] == '*' {
+					depth++
+					i += 2
+					continue
+				}
+				if script[i] == '*' && i+1 < len(script) && script[i+1] == '/' {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+				i++
+			}
+			comments = append(comments, sqlComment{text: strings.TrimSuffix(strings.TrimPrefix(script[start:min(i+1, len(script))], "/*"), "*/"), line: startLine})
+		case c == '\'':
+			escapes := i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i < 2 || !isIdentByte(script[i-2]))
+			for i++; i < len(script); i++ {
+				if script[i] == '\n' {
+					line++
+				}
+				if escapes && script[i] == '\\' {
+					i++
+					continue
+				}
+				if script[i] == '\'' {
+					if i+1 < len(script) && script[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			emit(sqlToken{text: "'…'", line: startLine, literal: true})
+		case c == '"':
+			var name strings.Builder
+			for i++; i < len(script); i++ {
+				if script[i] == '\n' {
+					line++
+				}
+				if script[i] == '"' {
+					if i+1 < len(script) && script[i+1] == '"' {
+						i++
+						name.WriteByte('"')
+						continue
+					}
+					break
+				}
+				name.WriteByte(script[i])
+			}
+			emit(sqlToken{text: name.String(), line: startLine, quoted: true})
+		case c == '$' && (i == 0 || !isIdentByte(script[i-1])):
+			tag, ok := dollarQuoteTag(script[i:])
+			if !ok {
+				emit(sqlToken{text: "$", line: startLine})
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				line += strings.Count(script[i:], "\n")
+				i = len(script)
+			} else {
+				body := script[i : i+len(tag)+end+len(tag)]
+				line += strings.Count(body, "\n")
+				i += len(body) - 1
+			}
+			emit(sqlToken{text: tag + "…" + tag, line: startLine, literal: true})
+		case c == ';' && blocks.depth == 0:
+			if len(current.tokens) > 0 {
+				current.lastLine = line
+				statements = append(statements, current)
+			}
+			current = tokenStatement{}
+			blocks = atomicBlocks{}
+		case isIdentByte(c):
+			for i+1 < len(script) && isIdentByte(script[i+1]) {
+				i++
+			}
+			blocks.word(script[start : i+1])
+			emit(sqlToken{text: script[start : i+1], line: startLine})
+		default:
+			emit(sqlToken{text: string(c), line: startLine})
+		}
+	}
+	if len(current.tokens) > 0 {
+		statements = append(statements, current)
+	}
+
+	return statements, comments
+}
@@ -0,0 +1,386 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// squashDirective marks a snapshot written by `tinytoe squash`.
+const squashDirective = "squash"
+
+// squashSchemaPrefix starts the name of the temporary schema Squash replays
+// migrations in.
+const squashSchemaPrefix = "tinytoe_squash_"
+
+// SquashResult describes a snapshot produced by Squash.
+type SquashResult struct {
+	// Version is the last squashed version; the snapshot takes it over.
+	Version string
+	// Filename is the snapshot's file name, "<version>_squashed.sql".
+	Filename string
+	// Squashed lists the migrations the snapshot replaces, oldest first.
+	Squashed []Migration
+	// SQL is the snapshot body, starting with the squash directive. The
+	// caller adds the usual migration header in front of it.
+	SQL string
+}
+
+// squashRecord names a snapshot whose covered rows are not yet marked in
+// tinytoe_migrations.
+type squashRecord struct {
+	version  string
+	filename string
+}
+
+// Squash renders a single migration equivalent to every migration up to and
+// including through. The migrations are replayed in a temporary schema that
+// is dumped and then dropped; repeatable migrations are left out, since they
+// keep running on their own. Squash refuses migrations that leave rows or
+// objects the snapshot cannot reproduce, and replays the snapshot in a
+// second temporary schema to prove it builds the same schema. It only reads
+// the target schema, refusing to continue when it stopped partway through
+// the range. Writing the snapshot and archiving the old files is up to the
+// caller, followed by RecordSquash.
+func (m *Migrator) Squash(ctx context.Context, through string) (result *SquashResult, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	through = strings.TrimSpace(through)
+	if through == "" {
+		return nil, fmt.Errorf("squash version is required")
+	}
+	files, err := discoverMigrations(m.opts.FS, m.opts.DumpFile)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &SquashResult{Version: through, Filename: through + "_squashed.sql", Squashed: []Migration{}}
+	found := false
+	for _, file := range files {
+		if file.version > through {
+			break
+		}
+		result.Squashed = append(result.Squashed, file.migration())
+		found = found || file.version == through
+	}
+	if !found {
+		return nil, fmt.Errorf("squash version %s does not match any migration in the migrations directory", through)
+	}
+	if len(result.Squashed) < 2 {
+		return nil, fmt.Errorf("nothing to squash: %s is the first migration", result.Squashed[0].Filename)
+	}
+
+	if err := m.ping(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.checkSquashable(ctx, files, through); err != nil {
+		return nil, err
+	}
+
+	scratch := m.scratchMigrator(squashSchemaPrefix)
+	scratch.opts.FS = withoutRepeatables{m.opts.FS}
+	scratch.opts.Hooks.Applied = nil
+	defer func() {
+		// Drop even when the run was cancelled.
+		dropErr := dropTargetSchema(context.WithoutCancel(ctx), m.db, scratch.opts.TargetSchema)
+		if dropErr != nil && err == nil {
+			err = fmt.Errorf("clean up squash schema: %w", dropErr)
+		}
+	}()
+
+	if err := scratch.Init(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := scratch.Up(ctx, UpOptions{Target: through}); err != nil {
+		return nil, fmt.Errorf("replay migrations through %s: %w", through, err)
+	}
+	var lost []string
+	err = inspectCatalog(ctx, m.db, scratch.opts.TargetSchema, func(d *dumper) error {
+		var err error
+		lost, err = d.unsupported()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(lost) > 0 {
+		return nil, fmt.Errorf("a snapshot of the migrations through %s would lose %s; squash through an earlier version or move these into migrations after it", through, strings.Join(lost, ", "))
+	}
+	body, err := dumpSchema(ctx, m.db, scratch.opts.TargetSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := result.Squashed[0].Filename, result.Squashed[len(result.Squashed)-1].Filename
+	result.SQL = fmt.Sprintf(`-- tinytoe:%s
+-- Schema snapshot replacing %d migrations, %s through %s.
+-- Databases that applied them record this file without running it; empty
+-- databases run it instead. Generated by tinytoe squash; do not edit by hand.
+
+SET LOCAL check_function_bodies = false;
+%s`, squashDirective, len(result.Squashed), first, last, body)
+
+	if err := m.checkSnapshot(ctx, scratch.opts.TargetSchema, body, result.SQL); err != nil {
+		return nil, fmt.Errorf("the snapshot of the migrations through %s %w", through, err)
+	}
+	return result, nil
+}
+
+// checkSnapshot runs a snapshot in a second temporary schema, as an empty
+// database would, and requires its catalog fingerprint and dump to match
+// those of original, the schema the squashed migrations built. The errors
+// read as the end of a sentence starting with the snapshot.
+func (m *Migrator) checkSnapshot(ctx context.Context, original, dump, snapshot string) (err error) {
+	schema := fmt.Sprintf("%s%d", squashSchemaPrefix, time.Now().UnixNano())
+	defer func() {
+		dropErr := dropTargetSchema(context.WithoutCancel(ctx), m.db, schema)
+		if dropErr != nil && err == nil {
+			err = fmt.Errorf("could not be checked: clean up replay schema: %w", dropErr)
+		}
+	}()
+
+	if err := ensureTargetSchema(ctx, m.db, schema); err != nil {
+		return fmt.Errorf("could not be checked: %w", err)
+	}
+	if err := replaySnapshot(ctx, m.db, schema, snapshot); err != nil {
+		return fmt.Errorf("does not apply to an empty schema: %w", err)
+	}
+
+	want, err := schemaFingerprint(ctx, m.db, original)
+	if err != nil {
+		return fmt.Errorf("could not be checked: %w", err)
+	}
+	got, err := schemaFingerprint(ctx, m.db, schema)
+	if err != nil {
+		return fmt.Errorf("could not be checked: %w", err)
+	}
+	if changes := diffSchemaObjects(want, got); len(changes) > 0 {
+		names := make([]string, len(changes))
+		for i, change := range changes {
+			names[i] = fmt.Sprintf("%s %s is %s", change.Kind, change.Name, change.Change)
+		}
+		return fmt.Errorf("does not reproduce the schema: on replay, %s", strings.Join(names, ", "))
+	}
+
+	replayed, err := dumpSchema(ctx, m.db, schema)
+	if err != nil {
+		return fmt.Errorf("could not be checked: %w", err)
+	}
+	if replayed != dump {
+		return fmt.Errorf("does not reproduce the schema: %s", firstDifference(dump, replayed))
+	}
+	return nil
+}
+
+// replaySnapshot runs a snapshot the way Up runs a transactional migration,
+// with schema alone on the search path.
+func replaySnapshot(ctx context.Context, db *sql.DB, schema, snapshot string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path = "+quoteIdent(schema)); err != nil {
+		return fmt.Errorf("set search_path: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, snapshot); err != nil {
+		if stmt, ok := failedStatement(snapshot, err); ok {
+			return fmt.Errorf("statement at line %d (%s): %w", stmt.line, excerpt(stmt.text), err)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// firstDifference names the first line on which two dumps differ.
+func firstDifference(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("dump line %d is %q on replay, expected %q", i+1, g, w)
+		}
+	}
+	return "dumps differ"
+}
+
+// checkSquashable refuses to squash a range that the target database applied
+// only part of, since it could never catch up afterwards.
+func (m *Migrator) checkSquashable(ctx context.Context, files []migrationFile, through string) error {
+	exists, err := migrationsTableExists(ctx, m.db, m.opts.TargetSchema)
+	if err != nil || !exists {
+		return err
+	}
+	applied, err := loadAppliedMigrations(ctx, m.db, m.opts.TargetSchema)
+	if err != nil {
+		return err
+	}
+	if applied, _, err = collapseSquashed(files, applied); err != nil {
+		return err
+	}
+	files = m.matchFiles(files, applied)
+	if err := detectDrift(files, applied); err != nil {
+		return err
+	}
+
+	if len(applied) == 0 || applied[0].version > through {
+		return nil
+	}
+	// Pending files come out in timestamp order, including any left behind
+	// out of order.
+	for _, file := range pendingMigrations(files, applied) {
+		if file.version <= through {
+			return fmt.Errorf("database has not applied %s; run tinytoe up --to %s before squashing through %s", file.filename, through, through)
+		}
+	}
+	return nil
+}
+
+// RecordSquash marks the target database's tinytoe_migrations rows covered by
+// a snapshot as squashed into it. It reports whether any row was marked; a
+// database that has not applied the squashed range is left alone and will
+// run the snapshot instead.
+func (m *Migrator) RecordSquash(ctx context.Context, result *SquashResult) (bool, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := m.ping(ctx); err != nil {
+		return false, err
+	}
+
+	lock, err := acquireMigrationLock(ctx, m.db, m.opts.TargetSchema, m.opts.LockWaitTimeout, m.opts.Hooks.LockWait)
+	if err != nil {
+		return false, err
+	}
+	defer lock.release()
+
+	exists, err := migrationsTableExists(ctx, m.db, m.opts.TargetSchema)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := ensureMigrationsTable(ctx, m.db, m.opts.TargetSchema); err != nil {
+		return false, err
+	}
+
+	snapshot := squashRecord{version: result.Version, filename: result.Filename}
+	if err := recordSquash(ctx, m.db, m.opts.TargetSchema, snapshot); err != nil {
+		return false, err
+	}
+
+	var marked int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE squashed_into = $1`, qualifyIdent(m.opts.TargetSchema, "tinytoe_migrations"))
+	if err := m.db.QueryRowContext(ctx, query, snapshot.filename).Scan(&marked); err != nil {
+		return false, fmt.Errorf("count squashed migrations: %w", err)
+	}
+	return marked > 0, nil
+}
+
+// recordSquash sets squashed_into on the rows a snapshot covers, provided the
+// database applied the original file at the snapshot's version rather than
+// the snapshot itself.
+func recordSquash(parent context.Context, db *sql.DB, schema string, snapshot squashRecord) error {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	table := qualifyIdent(schema, "tinytoe_migrations")
+	update := fmt.Sprintf(`
+UPDATE %s SET squashed_into = $1
+WHERE version <= $2
+  AND squashed_into IS DISTINCT FROM $1
+  AND EXISTS (SELECT 1 FROM %s WHERE version = $2 AND filename <> $1)`, table, table)
+	if _, err := db.ExecContext(ctx, update, snapshot.filename, snapshot.version); err != nil {
+		return fmt.Errorf("record squash into %s: %w", snapshot.filename, err)
+	}
+	return nil
+}
+
+// collapseSquashed replaces the applied rows covered by each squash snapshot
+// with a single row for the snapshot, so a database that applied the original
+// files matches a directory that holds only the snapshot. It also returns the
+// snapshots whose rows are not yet marked as squashed. A database that
+// stopped partway through a squashed range cannot catch up and is an error.
+func collapseSquashed(files []migrationFile, applied []appliedMigration) ([]appliedMigration, []squashRecord, error) {
+	if len(applied) == 0 {
+		return applied, nil, nil
+	}
+
+	var unrecorded []squashRecord
+	for _, file := range files {
+		data, err := readMigration(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		directives, err := parseDirectives(file.filename, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !directives.squash {
+			continue
+		}
+
+		// Applied rows are sorted by version, so the covered rows come first.
+		covered := 0
+		var through *appliedMigration
+		for i := range applied {
+			if applied[i].version > file.version {
+				break
+			}
+			covered++
+			if applied[i].version == file.version {
+				through = &applied[i]
+			}
+		}
+		switch {
+		case covered == 0:
+			continue
+		case through == nil:
+			return nil, nil, fmt.Errorf("detected drift: %s squashes migrations through %s, but the database stopped at %s; apply the remaining archived migrations from an older checkout first, or run `toe reset`", file.filename, file.version, applied[covered-1].version)
+		case through.filename == file.filename:
+			// The snapshot itself was applied to an empty database.
+			continue
+		}
+
+		recorded := true
+		for _, row := range applied[:covered] {
+			recorded = recorded && row.squashedInto == file.filename
+		}
+		if !recorded {
+			unrecorded = append(unrecorded, squashRecord{version: file.version, filename: file.filename})
+		}
+
+		collapsed := appliedMigration{
+			version:      file.version,
+			filename:     file.filename,
+			appliedAt:    through.appliedAt,
+			squashedInto: file.filename,
+			squashed:     true,
+		}
+		applied = append([]appliedMigration{collapsed}, applied[covered:]...)
+	}
+	return applied, unrecorded, nil
+}
+
+// withoutRepeatables hides the repeatable migrations directory, so a replay
+// applies versioned migrations only.
+type withoutRepeatables struct {
+	fs.FS
+}
+
+func (f withoutRepeatables) Open(name string) (fs.File, error) {
+	if name == repeatableDir || strings.HasPrefix(name, repeatableDir+"/") {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.FS.Open(name)
+}
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckSnapshotRejectsSnapshotsThatDiffer(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if strings.TrimSpace(dsn) == "" {
+		t.Skip("DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	original := fmt.Sprintf("tt_snapshot_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		_ = dropTargetSchema(context.Background(), db, original)
+	})
+	if err := ensureTargetSchema(ctx, db, original); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	if err := replaySnapshot(ctx, db, original, `
+CREATE TABLE widgets (id SERIAL PRIMARY KEY);
+CREATE FUNCTION all_widgets() RETURNS SETOF widgets LANGUAGE sql AS $$ SELECT * FROM widgets $$;
+`); err != nil {
+		t.Fatalf("build original schema: %v", err)
+	}
+	dump, err := dumpSchema(ctx, db, original)
+	if err != nil {
+		t.Fatalf("dump: %v", err)
+	}
+
+	m := &Migrator{db: db}
+	if err := m.checkSnapshot(ctx, original, dump, dump); err != nil {
+		t.Fatalf("expected the dump to reproduce its own schema, got %v", err)
+	}
+
+	// A function created before the table it returns cannot apply.
+	reordered := "CREATE FUNCTION all_widgets() RETURNS SETOF widgets LANGUAGE sql AS $$ SELECT * FROM widgets $$;\n" + dump
+	if err := m.checkSnapshot(ctx, original, dump, reordered); err == nil || !strings.Contains(err.Error(), "does not apply to an empty schema") {
+		t.Fatalf("expected a snapshot that fails to apply to be rejected, got %v", err)
+	}
+
+	// Without OWNED BY the sequence survives its column.
+	unowned := strings.Replace(dump, "ALTER SEQUENCE widgets_id_seq OWNED BY widgets.id;", "", 1)
+	if unowned == dump {
+		t.Fatalf("expected the dump to tie widgets_id_seq to its column, got:\n%s", dump)
+	}
+	if err := m.checkSnapshot(ctx, original, dump, unowned); err == nil || !strings.Contains(err.Error(), "does not reproduce the schema") {
+		t.Fatalf("expected a snapshot that builds a different schema to be rejected, got %v", err)
+	}
+}
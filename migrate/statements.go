@@ -0,0 +1,239 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// sqlStatement is a single statement split from a migration body.
+type sqlStatement struct {
+	text string
+	// line is the 1-based line on which the statement's first token appears.
+	line int
+}
+
+// splitStatements breaks a SQL script into individual statements on top-level
+// semicolons, respecting quoted strings, quoted identifiers, dollar-quoted
+// bodies, BEGIN ATOMIC bodies and comments. Statements consisting solely of
+// comments are dropped.
+func splitStatements(script string) []sqlStatement {
+	var statements []sqlStatement
+
+	line := 1
+	start := 0
+	startLine := 0 // line of the first significant token; 0 until one is seen
+	var blocks atomicBlocks
+
+	flush := func(end int) {
+		if startLine != 0 {
+			text := strings.TrimSpace(script[start:end])
+			statements = append(statements, sqlStatement{text: text, line: startLine})
+		}
+		start = end + 1
+		startLine = 0
+	}
+
+	markSignificant := func() {
+		if startLine == 0 {
+			startLine = line
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '\n':
+			line++
+		case c == '-' && i+1 < len(script) && script[i+1] == '-':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			if i < len(script) {
+				line++
+			}
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			depth := 0
+			for i < len(script) {
+				if script[i] == '\n' {
+					line++
+				}
+				if script[i] == '/' && i+1 < len(script) && script[i+1] == '*' {
+					depth++
+					i += 2
+					continue
+				}
+				if script[i] == '*' && i+1 < len(script) && script[i+1] == '/' {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+				i++
+			}
+		case c == '\'':
+			markSignificant()
+			escapes := i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i < 2 || !isIdentByte(script[i-2]))
+			for i++; i < len(script); i++ {
+				if script[i] == '\n' {
+					line++
+				}
+				if escapes && script[i] == '\\' {
+					i++
+					continue
+				}
+				if script[i] == '\'' {
+					if i+1 < len(script) && script[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '"':
+			markSignificant()
+			for i++; i < len(script); i++ {
+				if script[i] == '\n' {
+					line++
+				}
+				if script[i] == '"' {
+					if i+1 < len(script) && script[i+1] == '"' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '$' && (i == 0 || !isIdentByte(script[i-1])):
+			markSignificant()
+			tag, ok := dollarQuoteTag(script[i:])
+			if !ok {
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				line += strings.Count(script[i:], "\n")
+				i = len(script)
+				continue
+			}
+			body := script[i : i+len(tag)+end+len(tag)]
+			line += strings.Count(body, "\n")
+			i += len(body) - 1
+		case c == ';' && blocks.depth == 0:
+			flush(i)
+			blocks = atomicBlocks{}
+		case c == ' ' || c == '\t' || c == '\r':
+		case isIdentByte(c) && (i == 0 || !isIdentByte(script[i-1])):
+			markSignificant()
+			end := i
+			for end+1 < len(script) && isIdentByte(script[end+1]) {
+				end++
+			}
+			blocks.word(script[i : end+1])
+			i = end
+		default:
+			markSignificant()
+		}
+	}
+	flush(len(script))
+
+	return statements
+}
+
+// atomicBlocks tracks the BEGIN ATOMIC ... END bodies of SQL-standard
+// functions the way psql does, so the semicolons inside them do not end the
+// statement. BEGIN and CASE open a block unless they are the statement's
+// first word, which keeps a plain BEGIN from swallowing the script; END
+// closes one.
+type atomicBlocks struct {
+	words int
+	depth int
+}
+
+// word records the next word of the current statement.
+func (b *atomicBlocks) word(w string) {
+	b.words++
+	switch {
+	case strings.EqualFold(w, "begin") || strings.EqualFold(w, "case"):
+		if b.words > 1 {
+			b.depth++
+		}
+	case strings.EqualFold(w, "end"):
+		if b.depth > 0 {
+			b.depth--
+		}
+	}
+}
+
+// dollarQuoteTag returns the opening delimiter (e.g. "$$" or "$body$") when s
+// starts with a dollar quote.
+func dollarQuoteTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		switch {
+		case s[j] == '$':
+			return s[:j+1], true
+		case s[j] == '_' || ('a' <= s[j] && s[j] <= 'z') || ('A' <= s[j] && s[j] <= 'Z'):
+		case '0' <= s[j] && s[j] <= '9' && j > 1:
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c >= 0x80
+}
+
+// failedStatement finds the statement of script that err points into, using
+// the character position PostgreSQL reports for syntax and many semantic
+// errors.
+func failedStatement(script string, err error) (sqlStatement, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Position <= 0 {
+		return sqlStatement{}, false
+	}
+
+	// Position counts characters from 1, not bytes.
+	offset := 0
+	for chars := int32(1); chars < pgErr.Position && offset < len(script); chars++ {
+		_, size := utf8.DecodeRuneInString(script[offset:])
+		offset += size
+	}
+	line := 1 + strings.Count(script[:offset], "\n")
+
+	var found sqlStatement
+	ok := false
+	for _, stmt := range splitStatements(script) {
+		if stmt.line > line {
+			break
+		}
+		found, ok = stmt, true
+	}
+	return found, ok
+}
+
+// excerpt shortens a statement to its first line of SQL for error messages,
+// skipping the comments that precede it.
+func excerpt(statement string) string {
+	const limit = 60
+	lines := strings.Split(statement, "\n")
+	for len(lines) > 1 {
+		trimmed := strings.TrimSpace(lines[0])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			break
+		}
+		lines = lines[1:]
+	}
+	first, more := strings.TrimSpace(lines[0]), len(lines) > 1
+	if runes := []rune(first); len(runes) > limit {
+		first, more = string(runes[:limit]), true
+	}
+	if more {
+		first += " ..."
+	}
+	return first
+}
@@ -0,0 +1,217 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []sqlStatement
+	}{
+		{
+			name:   "empty",
+			script: "",
+			want:   nil,
+		},
+		{
+			name:   "comments only",
+			script: "-- nothing here\n/* or here */\n",
+			want:   nil,
+		},
+		{
+			name:   "top-level semicolons",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want: []sqlStatement{
+				{text: "CREATE TABLE a (id INT)", line: 1},
+				{text: "CREATE TABLE b (id INT)", line: 2},
+			},
+		},
+		{
+			name:   "missing final semicolon",
+			script: "SELECT 1;\n\nSELECT 2\n",
+			want: []sqlStatement{
+				{text: "SELECT 1", line: 1},
+				{text: "SELECT 2", line: 3},
+			},
+		},
+		{
+			name:   "line comment with semicolon",
+			script: "-- first; not a statement\nSELECT 1; -- trailing; comment\nSELECT 2;",
+			want: []sqlStatement{
+				{text: "-- first; not a statement\nSELECT 1", line: 2},
+				{text: "-- trailing; comment\nSELECT 2", line: 3},
+			},
+		},
+		{
+			name:   "nested block comments",
+			script: "/* outer /* inner; */ still; comment */ SELECT 1;\nSELECT 2;",
+			want: []sqlStatement{
+				{text: "/* outer /* inner; */ still; comment */ SELECT 1", line: 1},
+				{text: "SELECT 2", line: 2},
+			},
+		},
+		{
+			name:   "quoted strings and identifiers",
+			script: "INSERT INTO \"odd;name\" VALUES ('a;b', 'it''s; fine');\nSELECT 1;",
+			want: []sqlStatement{
+				{text: "INSERT INTO \"odd;name\" VALUES ('a;b', 'it''s; fine')", line: 1},
+				{text: "SELECT 1", line: 2},
+			},
+		},
+		{
+			name:   "escape strings",
+			script: "SELECT E'\\';still quoted';\nSELECT 2;",
+			want: []sqlStatement{
+				{text: "SELECT E'\\';still quoted'", line: 1},
+				{text: "SELECT 2", line: 2},
+			},
+		},
+		{
+			name:   "backslash in standard string",
+			script: "SELECT 'C:\\';\nSELECT 2;",
+			want: []sqlStatement{
+				{text: "SELECT 'C:\\'", line: 1},
+				{text: "SELECT 2", line: 2},
+			},
+		},
+		{
+			name:   "dollar-quoted bodies",
+			script: "CREATE FUNCTION f() RETURNS INT AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;\nDO $body$ BEGIN PERFORM 1; END $body$;",
+			want: []sqlStatement{
+				{text: "CREATE FUNCTION f() RETURNS INT AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql", line: 1},
+				{text: "DO $body$ BEGIN PERFORM 1; END $body$", line: 6},
+			},
+		},
+		{
+			name:   "positional parameters are not dollar quotes",
+			script: "PREPARE p AS SELECT $1;\nSELECT 2;",
+			want: []sqlStatement{
+				{text: "PREPARE p AS SELECT $1", line: 1},
+				{text: "SELECT 2", line: 2},
+			},
+		},
+		{
+			name:   "multi-line strings advance line numbers",
+			script: "SELECT 'a\nb';\nSELECT \"c\nd\";\nSELECT 3;",
+			want: []sqlStatement{
+				{text: "SELECT 'a\nb'", line: 1},
+				{text: "SELECT \"c\nd\"", line: 3},
+				{text: "SELECT 3", line: 5},
+			},
+		},
+		{
+			name:   "begin atomic bodies",
+			script: "CREATE FUNCTION f() RETURNS INT LANGUAGE sql\nBEGIN ATOMIC\n  SELECT CASE WHEN true THEN 1 END;\n  SELECT 2;\nEND;\nSELECT 3;",
+			want: []sqlStatement{
+				{text: "CREATE FUNCTION f() RETURNS INT LANGUAGE sql\nBEGIN ATOMIC\n  SELECT CASE WHEN true THEN 1 END;\n  SELECT 2;\nEND", line: 1},
+				{text: "SELECT 3", line: 6},
+			},
+		},
+		{
+			name:   "transaction blocks",
+			script: "BEGIN;\nSELECT 1;\nEND;\nSELECT CASE WHEN true THEN 1 END;\nSELECT 2;",
+			want: []sqlStatement{
+				{text: "BEGIN", line: 1},
+				{text: "SELECT 1", line: 2},
+				{text: "END", line: 3},
+				{text: "SELECT CASE WHEN true THEN 1 END", line: 4},
+				{text: "SELECT 2", line: 5},
+			},
+		},
+		{
+			name:   "empty statements",
+			script: ";;SELECT 1;;",
+			want: []sqlStatement{
+				{text: "SELECT 1", line: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitStatements(%q)\n got: %#v\nwant: %#v", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailedStatement(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    error
+		want   sqlStatement
+		wantOK bool
+	}{
+		{
+			name:   "not a PostgreSQL error",
+			script: "SELECT 1;",
+			err:    errors.New("connection reset"),
+		},
+		{
+			name:   "no position",
+			script: "SELECT 1;",
+			err:    &pgconn.PgError{Code: "23505"},
+		},
+		{
+			name:   "position in a later statement",
+			script: "CREATE TABLE a (id INT);\nCREATE TABL b (id INT);\n",
+			err:    &pgconn.PgError{Position: 33},
+			want:   sqlStatement{text: "CREATE TABL b (id INT)", line: 2},
+			wantOK: true,
+		},
+		{
+			name:   "position counts characters, not bytes",
+			script: "SELECT 'é';\nSELEC 2;",
+			err:    &pgconn.PgError{Position: 13},
+			want:   sqlStatement{text: "SELEC 2", line: 2},
+			wantOK: true,
+		},
+		{
+			name:   "position past the end",
+			script: "SELECT 1;\nSELECT 2;",
+			err:    &pgconn.PgError{Position: 500},
+			want:   sqlStatement{text: "SELECT 2", line: 2},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := failedStatement(tt.script, tt.err)
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("failedStatement() = %#v, %v; want %#v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{name: "single line", statement: "SELECT 1", want: "SELECT 1"},
+		{name: "leading comments and blank lines", statement: "-- seed data\n\nINSERT INTO widgets (id) VALUES (1)", want: "INSERT INTO widgets (id) VALUES (1)"},
+		{name: "multi-line statement", statement: "CREATE TABLE widgets (\n  id INT\n)", want: "CREATE TABLE widgets ( ..."},
+		{name: "long line", statement: strings.Repeat("é", 70), want: strings.Repeat("é", 60) + " ..."},
+		{name: "comment only", statement: "-- nothing", want: "-- nothing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := excerpt(tt.statement); got != tt.want {
+				t.Fatalf("excerpt(%q) = %q, want %q", tt.statement, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// Migration states reported by Status.
+const (
+	StateApplied   = "applied"
+	StateBaselined = "baselined"
+	StateSquashed  = "squashed"
+	StatePending   = "pending"
+	StateDrift     = "drift"
+)
+
+// StatusResult describes how the migrations directory compares with the
+// database.
+type StatusResult struct {
+	// Initialized reports whether the tinytoe_migrations table exists.
+	Initialized bool
+	// Migrations lists versioned migrations in order, followed by any applied
+	// rows whose files are missing, then repeatable migrations.
+	Migrations []MigrationStatus
+	// Applied counts rows recorded in tinytoe_migrations.
+	Applied int
+	// Pending counts versioned and repeatable migrations waiting to run.
+	Pending int
+	// Drift is the first drift problem found, the same error Up would return.
+	Drift error
+}
+
+// MigrationStatus describes the state of a single migration.
+type MigrationStatus struct {
+	Migration
+	// State is one of StateApplied, StateBaselined, StateSquashed,
+	// StatePending or StateDrift. StateSquashed marks a squash snapshot
+	// standing in for migrations the database applied before the squash.
+	State string
+	// AppliedAt is when the migration was recorded; zero when never applied.
+	AppliedAt time.Time
+	// Detail explains drift, marks a repeatable migration as "changed", or
+	// marks a migration applied or pending "out of order".
+	Detail string
+}
+
+// Status compares the migrations directory with the database without
+// modifying either. Drift is reported in the result rather than as an error.
+func (m *Migrator) Status(ctx context.Context) (*StatusResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cfg := m.opts
+
+	files, err := discoverMigrations(cfg.FS, cfg.DumpFile)
+	if err != nil {
+		return nil, err
+	}
+	repeatables, err := discoverRepeatables(cfg.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ping(ctx); err != nil {
+		return nil, err
+	}
+
+	exists, err := migrationsTableExists(ctx, m.db, cfg.TargetSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []appliedMigration
+	if exists {
+		applied, err = loadAppliedMigrations(ctx, m.db, cfg.TargetSchema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	collapsed, _, squashErr := collapseSquashed(files, applied)
+	if squashErr == nil {
+		applied = collapsed
+	}
+	files = m.matchFiles(files, applied)
+
+	appliedRepeatables, err := loadAppliedRepeatables(ctx, m.db, cfg.TargetSchema)
+	if err != nil {
+		return nil, err
+	}
+	repeatableEntries, err := repeatableStatusEntries(repeatables, appliedRepeatables)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StatusResult{
+		Initialized: exists,
+		Migrations:  append(statusEntries(files, applied), repeatableEntries...),
+		Applied:     len(applied),
+		Pending:     len(pendingMigrations(files, applied)),
+		Drift:       detectDrift(files, applied),
+	}
+	if squashErr != nil {
+		result.Drift = squashErr
+	}
+	for _, entry := range repeatableEntries {
+		if entry.State == StatePending {
+			result.Pending++
+		}
+	}
+	return result, nil
+}
+
+// statusEntries pairs files and applied records positionally, mirroring
+// detectDrift, so every mismatch is highlighted rather than only the first.
+func statusEntries(files []migrationFile, applied []appliedMigration) []MigrationStatus {
+	outOfOrder := appliedOutOfOrder(applied)
+	entries := make([]MigrationStatus, 0, len(files))
+	for i, file := range files {
+		entry := MigrationStatus{Migration: file.migration()}
+		switch {
+		case i >= len(applied):
+			entry.State = StatePending
+			if isOutOfOrder(file, applied) {
+				entry.Detail = "out of order"
+			}
+		case checkAppliedMigration(file, applied[i]) != nil:
+			entry.State = StateDrift
+			entry.Detail = fmt.Sprintf("database lists %s", applied[i].filename)
+			if file.filename == applied[i].filename {
+				entry.Detail = "modified after it was applied"
+				if _, err := fs.Stat(file.fsys, file.path); err != nil {
+					// A placeholder for a missing file, see matchFilesByVersion.
+					entry.AppliedAt = applied[i].appliedAt
+					entry.Detail = "applied " + applied[i].appliedAt.UTC().Format(time.RFC3339) + ", file missing"
+				}
+			}
+		default:
+			entry.State = StateApplied
+			if applied[i].baselined {
+				entry.State = StateBaselined
+			}
+			if applied[i].squashed {
+				entry.State = StateSquashed
+			}
+			if outOfOrder[i] {
+				entry.Detail = "out of order"
+			}
+			entry.AppliedAt = applied[i].appliedAt
+		}
+		entries = append(entries, entry)
+	}
+
+	for i := len(files); i < len(applied); i++ {
+		entries = append(entries, MigrationStatus{
+			Migration: Migration{Version: applied[i].version, Filename: applied[i].filename},
+			State:     StateDrift,
+			AppliedAt: applied[i].appliedAt,
+			Detail:    "applied " + applied[i].appliedAt.UTC().Format(time.RFC3339) + ", file missing",
+		})
+	}
+
+	return entries
+}
+
+// repeatableStatusEntries reports each repeatable migration as applied when
+// its recorded checksum matches the file, or pending when it is new or changed.
+func repeatableStatusEntries(files []migrationFile, applied map[string]appliedRepeatable) ([]MigrationStatus, error) {
+	entries := make([]MigrationStatus, 0, len(files))
+	for _, file := range files {
+		sum, err := fileChecksum(file)
+		if err != nil {
+			return nil, err
+		}
+		entry := MigrationStatus{Migration: file.migration(), State: StatePending}
+		row, ok := applied[file.filename]
+		switch {
+		case !ok:
+		case row.checksum != sum:
+			entry.Detail = "changed"
+		default:
+			entry.State = StateApplied
+			entry.AppliedAt = row.appliedAt
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SchemaSummary describes what dropping the target schema would destroy. Row
+// counts and sizes come from pg_class statistics, so they are approximate.
+type SchemaSummary struct {
+	// Database is the name of the connected database.
+	Database string
+	// Schema is the target schema.
+	Schema string
+	// Exists reports whether the schema exists at all.
+	Exists bool
+	// Tables counts tables, partitioned tables and materialized views.
+	Tables int
+	// ApproxRows sums pg_class.reltuples; tables never analyzed count as zero.
+	ApproxRows int64
+	// SizeBytes is the on-disk size of the tables and materialized views,
+	// including their indexes and TOAST data.
+	SizeBytes int64
+}
+
+// Summarize reports what DropAll would destroy in the target schema.
+func (m *Migrator) Summarize(parent context.Context) (*SchemaSummary, error) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if err := m.ping(parent); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	summary := &SchemaSummary{Schema: m.opts.TargetSchema}
+	err := m.db.QueryRowContext(ctx, `
+SELECT current_database(),
+       EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1),
+       COUNT(c.oid) FILTER (WHERE c.relkind IN ('r', 'p', 'm')),
+       COALESCE(SUM(GREATEST(c.reltuples, 0)) FILTER (WHERE c.relkind IN ('r', 'm')), 0)::BIGINT,
+       COALESCE(SUM(pg_total_relation_size(c.oid)) FILTER (WHERE c.relkind IN ('r', 'm')), 0)::BIGINT
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+`, m.opts.TargetSchema).Scan(&summary.Database, &summary.Exists, &summary.Tables, &summary.ApproxRows, &summary.SizeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("summarize schema: %w", err)
+	}
+	return summary, nil
+}
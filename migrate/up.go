@@ -0,0 +1,799 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"tinytoe/internal/version"
+)
+
+// UpOptions tune how Up behaves for individual invocations.
+type UpOptions struct {
+	// DryRun plans the migrations that would be applied, including their SQL,
+	// without modifying the database.
+	DryRun bool
+	// Target, when set, applies pending migrations up to and including this
+	// version and stops.
+	Target string
+	// Steps, when positive, applies at most this many pending migrations.
+	Steps int
+}
+
+// UpResult describes the outcome of Up.
+type UpResult struct {
+	// Applied lists the migrations applied by this run in order, versioned
+	// migrations first and then repeatable ones. When Up fails part way it
+	// holds those applied before the failure.
+	Applied []Migration
+	// Planned lists what a dry run would apply; it is empty for real runs.
+	Planned []PlannedMigration
+	// Remaining counts versioned migrations left pending by Target or Steps.
+	Remaining int
+	// Failed is the migration whose application failed, if any.
+	Failed *Migration
+}
+
+// PlannedMigration is a pending migration reported by a dry run.
+type PlannedMigration struct {
+	Migration
+	// SQL is the file body as it would be executed.
+	SQL string
+	// NoTransaction reports the tinytoe:no-transaction directive.
+	NoTransaction bool
+	// OutOfOrder reports a migration older than the latest applied one, which
+	// only Options.AllowOutOfOrder lets through.
+	OutOfOrder bool
+}
+
+// Up applies pending migrations in timestamp order, followed by new or
+// changed repeatable migrations. Dry runs share discovery, drift detection and
+// pending selection with real runs so a plan cannot disagree with what would
+// be applied. Drift is returned as an error. A successful run records a
+// fingerprint of the target schema's catalog for CheckSchema; changes made
+// outside Tiny Toe since the previous one are first reported to
+// Hooks.SchemaChanged.
+func (m *Migrator) Up(ctx context.Context, opts UpOptions) (*UpResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cfg := m.opts
+	result := &UpResult{Applied: []Migration{}}
+
+	files, err := discoverMigrations(cfg.FS, cfg.DumpFile)
+	if err != nil {
+		return nil, err
+	}
+	repeatables, err := discoverRepeatables(cfg.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.ping(ctx); err != nil {
+		return nil, err
+	}
+
+	// Serialize concurrent runners before any applied state is read.
+	lock, err := acquireMigrationLock(ctx, m.db, cfg.TargetSchema, cfg.LockWaitTimeout, cfg.Hooks.LockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
+	exists, err := migrationsTableExists(ctx, m.db, cfg.TargetSchema)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		if err := m.prepare(ctx); err != nil {
+			return nil, err
+		}
+		if !exists {
+			if cfg.Hooks.Initialized != nil {
+				cfg.Hooks.Initialized()
+			}
+			exists = true
+		}
+	}
+
+	var applied []appliedMigration
+	if exists {
+		applied, err = loadAppliedMigrations(ctx, m.db, cfg.TargetSchema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	applied, unrecorded, err := collapseSquashed(files, applied)
+	if err != nil {
+		return nil, err
+	}
+	files = m.matchFiles(files, applied)
+	if err := detectDrift(files, applied); err != nil {
+		return nil, err
+	}
+	if !opts.DryRun {
+		for _, snapshot := range unrecorded {
+			if err := recordSquash(ctx, m.db, cfg.TargetSchema, snapshot); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	pending := pendingMigrations(files, applied)
+	remaining := len(pending)
+	pending, err = limitPending(files, applied, pending, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Remaining = remaining - len(pending)
+
+	// Repeatable migrations run after the versioned ones, and only once no
+	// versioned migrations are held back by Target or Steps.
+	if result.Remaining == 0 && len(repeatables) > 0 {
+		appliedRepeatables, err := loadAppliedRepeatables(ctx, m.db, cfg.TargetSchema)
+		if err != nil {
+			return nil, err
+		}
+		repeatablePending, err := pendingRepeatables(repeatables, appliedRepeatables)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, repeatablePending...)
+	}
+
+	if opts.DryRun {
+		result.Planned, err = planMigrations(pending, applied)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if err := backfillChecksums(ctx, m.db, cfg.TargetSchema, files, applied); err != nil {
+		return nil, err
+	}
+
+	// The fingerprint recorded after applying would absorb changes made by
+	// hand, so compare against the previous one while they can be told apart.
+	if len(pending) > 0 {
+		if err := m.reportSchemaChanges(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range pending {
+		if isOutOfOrder(file, applied) && cfg.Hooks.OutOfOrder != nil {
+			cfg.Hooks.OutOfOrder(file.migration(), applied[len(applied)-1].filename)
+		}
+		if err := applyMigration(ctx, m.db, cfg, file, m.eachStatement); err != nil {
+			failed := file.migration()
+			result.Failed = &failed
+			return result, err
+		}
+		result.Applied = append(result.Applied, file.migration())
+		if cfg.Hooks.Applied != nil {
+			cfg.Hooks.Applied(file.migration())
+		}
+	}
+
+	// Fingerprint the catalog so CheckSchema can spot changes made by hand
+	// later. A run that applied nothing keeps the previous fingerprint.
+	if err := recordSchemaFingerprint(ctx, m.db, cfg.TargetSchema, len(result.Applied) > 0); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// planMigrations reads the pending files and their directives for a dry run.
+func planMigrations(pending []migrationFile, applied []appliedMigration) ([]PlannedMigration, error) {
+	planned := make([]PlannedMigration, 0, len(pending))
+	for _, file := range pending {
+		data, err := readMigration(file)
+		if err != nil {
+			return nil, err
+		}
+		directives, err := parseDirectives(file.filename, data)
+		if err != nil {
+			return nil, err
+		}
+		planned = append(planned, PlannedMigration{
+			Migration:     file.migration(),
+			SQL:           string(data),
+			NoTransaction: directives.noTransaction,
+			OutOfOrder:    isOutOfOrder(file, applied),
+		})
+	}
+	return planned, nil
+}
+
+type migrationFile struct {
+	version  string
+	filename string
+	// fsys and path locate the file; path is slash-separated and relative to
+	// the root of fsys.
+	fsys fs.FS
+	path string
+	// repeatable marks files from the repeatable subdirectory, which have no
+	// version and are tracked by checksum in a companion table.
+	repeatable bool
+}
+
+func (f migrationFile) migration() Migration {
+	return Migration{Version: f.version, Filename: f.filename, Repeatable: f.repeatable}
+}
+
+type appliedMigration struct {
+	version   string
+	filename  string
+	appliedAt time.Time
+	// checksum is empty for rows recorded before checksums were tracked.
+	checksum string
+	// baselined marks rows recorded by `tinytoe baseline` without running SQL.
+	baselined bool
+	// squashedInto names the snapshot that replaced this row's file, once
+	// recorded by `tinytoe squash` or `tinytoe up`.
+	squashedInto string
+	// squashed marks the single row collapseSquashed puts in place of the
+	// rows a snapshot covers; it has no checksum of its own.
+	squashed bool
+	// appliedOrder numbers rows in the order they were recorded; zero for rows
+	// recorded before applied_order was tracked.
+	appliedOrder int64
+}
+
+func migrationsTableExists(ctx context.Context, db *sql.DB, schema string) (bool, error) {
+	return tableExists(ctx, db, schema, "tinytoe_migrations")
+}
+
+func tableExists(parent context.Context, db *sql.DB, schema, table string) (bool, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	query := `
+SELECT COUNT(*) FROM information_schema.tables
+WHERE table_schema = $1 AND table_name = $2
+`
+	var count int
+	if err := db.QueryRowContext(ctx, query, schema, table).Scan(&count); err != nil {
+		return false, fmt.Errorf("check %s table: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// discoverMigrations lists the versioned migrations at the root of fsys in
+// version order, skipping schema snapshots named SchemaDumpFile or dumpFile.
+func discoverMigrations(fsys fs.FS, dumpFile string) ([]migrationFile, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations directory: %w", err)
+	}
+
+	var files []migrationFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".sql") || name == SchemaDumpFile || name == dumpFile {
+			continue
+		}
+		if len(name) < 20 { // 14 digits + "_" + at least one char + ".sql"
+			return nil, fmt.Errorf("invalid migration filename: %s", name)
+		}
+
+		version := name[:14]
+		if !isDigits(version) {
+			return nil, fmt.Errorf("invalid migration version in %s", name)
+		}
+		if name[14] != '_' {
+			return nil, fmt.Errorf("invalid migration filename: %s", name)
+		}
+
+		files = append(files, migrationFile{
+			version:  version,
+			filename: name,
+			fsys:     fsys,
+			path:     name,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].version == files[j].version {
+			return files[i].filename < files[j].filename
+		}
+		return files[i].version < files[j].version
+	})
+
+	for i := 1; i < len(files); i++ {
+		if files[i].version == files[i-1].version {
+			return nil, fmt.Errorf("duplicate migration version %s (%s and %s)", files[i].version, files[i-1].filename, files[i].filename)
+		}
+	}
+
+	return files, nil
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func loadAppliedMigrations(parent context.Context, db *sql.DB, schema string) ([]appliedMigration, error) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+
+	// Read-only callers (status, dry runs) may see tables that predate newer
+	// columns, so optional columns are selected only when present.
+	columns, err := migrationsTableColumns(ctx, db, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT version, filename, applied_at, %s, %s, %s, %s FROM %s ORDER BY version`,
+		optionalColumn(columns, "checksum"),
+		optionalColumn(columns, "baselined"),
+		optionalColumn(columns, "squashed_into"),
+		optionalColumn(columns, "applied_order"),
+		qualifyIdent(schema, "tinytoe_migrations"))
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("load applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []appliedMigration
+	for rows.Next() {
+		var row appliedMigration
+		var checksum, squashedInto sql.NullString
+		var baselined sql.NullBool
+		var appliedOrder sql.NullInt64
+		if err := rows.Scan(&row.version, &row.filename, &row.appliedAt, &checksum, &baselined, &squashedInto, &appliedOrder); err != nil {
+			return nil, fmt.Errorf("scan applied migration: %w", err)
+		}
+		row.checksum = checksum.String
+		row.baselined = baselined.Bool
+		row.squashedInto = squashedInto.String
+		row.appliedOrder = appliedOrder.Int64
+		applied = append(applied, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate applied migrations: %w", err)
+	}
+	return applied, nil
+}
+
+func migrationsTableColumns(ctx context.Context, db *sql.DB, schema string) (map[string]bool, error) {
+	query := `
+SELECT column_name FROM information_schema.columns
+WHERE table_schema = $1 AND table_name = 'tinytoe_migrations'
+`
+	rows, err := db.QueryContext(ctx, query, schema)
+	if err != nil {
+		return nil, fmt.Errorf("inspect migrations table: %w", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan migrations table column: %w", err)
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate migrations table columns: %w", err)
+	}
+	return columns, nil
+}
+
+func optionalColumn(columns map[string]bool, name string) string {
+	if columns[name] {
+		return quoteIdent(name)
+	}
+	return "NULL"
+}
+
+func detectDrift(files []migrationFile, applied []appliedMigration) error {
+	if len(applied) > len(files) {
+		return fmt.Errorf("detected drift: database reports more migrations than available files; run `toe reset` to reconcile")
+	}
+
+	for i, appliedMigration := range applied {
+		if err := checkAppliedMigration(files[i], appliedMigration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkAppliedMigration verifies that the file at an applied position still
+// matches the database record.
+func checkAppliedMigration(file migrationFile, applied appliedMigration) error {
+	if file.version < applied.version {
+		// Both lists are sorted, so the file was never applied.
+		return fmt.Errorf("detected drift: migration %s is older than applied migration %s; apply it with --allow-out-of-order or run `toe reset`", file.filename, applied.filename)
+	}
+	if file.version != applied.version {
+		return fmt.Errorf("detected drift: expected migration %s but database lists %s; run `toe reset`", file.filename, applied.filename)
+	}
+	if file.filename != applied.filename {
+		return fmt.Errorf("detected drift: migration %s recorded as %s in database; run `toe reset`", file.filename, applied.filename)
+	}
+
+	diskFile, err := fs.Stat(file.fsys, file.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("detected drift: applied migration %s no longer exists; run `toe reset`", applied.filename)
+		}
+		return fmt.Errorf("stat migration %s: %w", applied.filename, err)
+	}
+	if !diskFile.Mode().IsRegular() {
+		return fmt.Errorf("detected drift: migration %s is no longer a regular file; run `toe reset`", applied.filename)
+	}
+
+	if applied.checksum != "" {
+		sum, err := fileChecksum(file)
+		if err != nil {
+			return err
+		}
+		if sum != applied.checksum {
+			return fmt.Errorf("detected drift: migration %s was modified after it was applied; run `toe reset`", applied.filename)
+		}
+	}
+
+	return nil
+}
+
+func fileChecksum(file migrationFile) (string, error) {
+	data, err := readMigration(file)
+	if err != nil {
+		return "", err
+	}
+	return checksum(data), nil
+}
+
+// checksum returns the hex-encoded SHA-256 digest of a migration body.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// backfillChecksums records checksums for rows applied before checksums were
+// tracked, trusting the current file contents once drift checks have passed.
+func backfillChecksums(parent context.Context, db *sql.DB, schema string, files []migrationFile, applied []appliedMigration) error {
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	defer cancel()
+
+	update := fmt.Sprintf(`UPDATE %s SET checksum = $1 WHERE version = $2 AND checksum IS NULL`, qualifyIdent(schema, "tinytoe_migrations"))
+	for i, row := range applied {
+		if row.checksum != "" || row.squashed {
+			continue
+		}
+		sum, err := fileChecksum(files[i])
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, update, sum, row.version); err != nil {
+			return fmt.Errorf("record checksum for %s: %w", row.filename, err)
+		}
+	}
+	return nil
+}
+
+// limitPending trims the pending list according to --to or --step. The target
+// must name a discovered migration that has not already been passed.
+func limitPending(files []migrationFile, applied []appliedMigration, pending []migrationFile, opts UpOptions) ([]migrationFile, error) {
+	if opts.Target != "" && opts.Steps != 0 {
+		return nil, fmt.Errorf("--to and --step cannot be combined")
+	}
+	if opts.Steps < 0 {
+		return nil, fmt.Errorf("--step must be a positive number, got %d", opts.Steps)
+	}
+
+	if opts.Steps > 0 {
+		if opts.Steps < len(pending) {
+			return pending[:opts.Steps], nil
+		}
+		return pending, nil
+	}
+
+	if opts.Target == "" {
+		return pending, nil
+	}
+
+	found := false
+	for _, file := range files {
+		if file.version == opts.Target {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("target version %s does not match any migration in the migrations directory", opts.Target)
+	}
+
+	// A pending target may be older than the latest applied migration when
+	// out-of-order migrations are allowed.
+	targetPending := false
+	for _, file := range pending {
+		targetPending = targetPending || file.version == opts.Target
+	}
+	if len(applied) > 0 && !targetPending {
+		latest := applied[len(applied)-1].version
+		if opts.Target < latest {
+			return nil, fmt.Errorf("target version %s is behind the applied state (latest applied %s); migrations cannot be rolled back", opts.Target, latest)
+		}
+	}
+
+	limited := make([]migrationFile, 0, len(pending))
+	for _, file := range pending {
+		if file.version > opts.Target {
+			break
+		}
+		limited = append(limited, file)
+	}
+	return limited, nil
+}
+
+func pendingMigrations(files []migrationFile, applied []appliedMigration) []migrationFile {
+	pending := make([]migrationFile, 0)
+	appliedCount := len(applied)
+	for i := appliedCount; i < len(files); i++ {
+		pending = append(pending, files[i])
+	}
+	return pending
+}
+
+func readMigration(file migrationFile) ([]byte, error) {
+	data, err := fs.ReadFile(file.fsys, file.path)
+	if err != nil {
+		return nil, fmt.Errorf("read migration %s: %w", file.filename, err)
+	}
+	return data, nil
+}
+
+func applyMigration(parent context.Context, db *sql.DB, cfg Options, file migrationFile, eachStatement bool) error {
+	data, err := readMigration(file)
+	if err != nil {
+		return err
+	}
+
+	directives, err := parseDirectives(file.filename, data)
+	if err != nil {
+		return err
+	}
+
+	ctx := parent
+	timeout := cfg.MigrationTimeout
+	if directives.timeout != nil {
+		timeout = *directives.timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, timeout)
+		defer cancel()
+	}
+
+	settings := sessionSettings(cfg, directives)
+
+	if directives.noTransaction {
+		err = applyMigrationWithoutTransaction(ctx, db, cfg, settings, file, data)
+	} else {
+		err = applyMigrationInTransaction(ctx, db, cfg, settings, file, data, eachStatement)
+	}
+	// Only cancellation of the caller's context counts as an interruption; a
+	// migration timeout is reported as the failure it is.
+	if err != nil && parent.Err() != nil {
+		return &InterruptedError{Migration: file.migration(), RolledBack: !directives.noTransaction, Err: err}
+	}
+	return err
+}
+
+// applyMigrationInTransaction runs the body and its bookkeeping row in one
+// transaction, so a failure or cancellation leaves no trace. The body is sent
+// whole, as PostgreSQL parses it; a failure names the statement when
+// PostgreSQL reports a position, or, with eachStatement set, by replaying the
+// body one statement at a time.
+func applyMigrationInTransaction(ctx context.Context, db *sql.DB, cfg Options, settings []string, file migrationFile, data []byte, eachStatement bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction for %s: %w", file.filename, err)
+	}
+
+	for _, setting := range settings {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL "+setting); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("set %s for %s: %w", setting, file.filename, err)
+		}
+	}
+
+	started := time.Now()
+	if _, err := tx.ExecContext(ctx, string(data)); err != nil {
+		_ = tx.Rollback()
+		stmt, ok := failedStatement(string(data), err)
+		if !ok && eachStatement && ctx.Err() == nil {
+			stmt, ok = locateFailedStatement(ctx, db, settings, data)
+		}
+		if ok {
+			return fmt.Errorf("execute migration %s: statement at line %d (%s): %w", file.filename, stmt.line, excerpt(stmt.text), err)
+		}
+		return fmt.Errorf("execute migration %s: %w", file.filename, err)
+	}
+
+	if err := recordMigration(ctx, tx, cfg, file, data, time.Since(started)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration %s: %w", file.filename, err)
+	}
+
+	return nil
+}
+
+// locateFailedStatement replays a failed migration body one statement at a
+// time in a transaction it rolls back, returning the first statement that
+// fails. It finds nothing when the replay fails before the body or succeeds.
+func locateFailedStatement(ctx context.Context, db *sql.DB, settings []string, data []byte) (sqlStatement, bool) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqlStatement{}, false
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, setting := range settings {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL "+setting); err != nil {
+			return sqlStatement{}, false
+		}
+	}
+	for _, stmt := range splitStatements(string(data)) {
+		if _, err := tx.ExecContext(ctx, stmt.text); err != nil {
+			return stmt, true
+		}
+	}
+	return sqlStatement{}, false
+}
+
+// InterruptedError reports a migration cut short because the context passed
+// to Up was cancelled, e.g. by SIGINT.
+type InterruptedError struct {
+	// Migration is the file that was running.
+	Migration Migration
+	// RolledBack is false for no-transaction migrations, whose completed
+	// statements were kept.
+	RolledBack bool
+	// Err is the error returned by the interrupted statement.
+	Err error
+}
+
+func (e *InterruptedError) Error() string {
+	if e.RolledBack {
+		return fmt.Sprintf("migration %s was interrupted; its transaction was rolled back and it remains pending", e.Migration.Filename)
+	}
+	return fmt.Sprintf("migration %s was interrupted while running outside a transaction; statements that completed were kept, so it needs manual attention before rerunning", e.Migration.Filename)
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// sessionSettings lists the `name = value` assignments issued before a
+// migration body: the search_path plus any lock or statement timeouts, with
+// file directives taking precedence over the run-wide configuration.
+func sessionSettings(cfg Options, directives migrationDirectives) []string {
+	settings := []string{"search_path = " + quoteIdent(cfg.TargetSchema)}
+
+	lockTimeout, setLock := cfg.LockTimeout, cfg.LockTimeout > 0
+	if directives.lockTimeout != nil {
+		lockTimeout, setLock = *directives.lockTimeout, true
+	}
+	if setLock {
+		settings = append(settings, fmt.Sprintf("lock_timeout = %d", timeoutMillis(lockTimeout)))
+	}
+
+	statementTimeout, setStatement := cfg.StatementTimeout, cfg.StatementTimeout > 0
+	if directives.statementTimeout != nil {
+		statementTimeout, setStatement = *directives.statementTimeout, true
+	}
+	if setStatement {
+		settings = append(settings, fmt.Sprintf("statement_timeout = %d", timeoutMillis(statementTimeout)))
+	}
+
+	return settings
+}
+
+// timeoutMillis converts a timeout to the whole milliseconds PostgreSQL
+// expects, rounding up so a sub-millisecond timeout never becomes 0, which
+// would disable it. Zero stays zero.
+func timeoutMillis(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// applyMigrationWithoutTransaction runs each statement on its own so commands
+// that refuse to run inside a transaction block (CREATE INDEX CONCURRENTLY,
+// VACUUM, ...) can be used. A failure after the first statement leaves earlier
+// statements committed, so errors say so explicitly.
+func applyMigrationWithoutTransaction(ctx context.Context, db *sql.DB, cfg Options, settings []string, file migrationFile, data []byte) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("reserve connection for %s: %w", file.filename, err)
+	}
+	defer conn.Close()
+
+	// Session-level settings must not leak to the next user of the connection.
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "RESET ALL")
+	}()
+	for _, setting := range settings {
+		if _, err := conn.ExecContext(ctx, "SET "+setting); err != nil {
+			return fmt.Errorf("set %s for %s: %w", setting, file.filename, err)
+		}
+	}
+
+	started := time.Now()
+	statements := splitStatements(string(data))
+	for i, stmt := range statements {
+		if _, err := conn.ExecContext(ctx, stmt.text); err != nil {
+			if i == 0 {
+				return fmt.Errorf("execute migration %s (no transaction): statement at line %d: %w", file.filename, stmt.line, err)
+			}
+			return fmt.Errorf("execute migration %s (no transaction): statement %d of %d at line %d failed after earlier statements were committed; the database needs manual attention before rerunning: %w", file.filename, i+1, len(statements), stmt.line, err)
+		}
+	}
+
+	if err := recordMigration(ctx, conn, cfg, file, data, time.Since(started)); err != nil {
+		return fmt.Errorf("%w; migration %s ran outside a transaction and its changes were kept, so it needs manual attention before rerunning", err, file.filename)
+	}
+
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// recordMigration inserts the bookkeeping row for an applied migration along
+// with who applied it, from which build, and how long the body took to run.
+func recordMigration(ctx context.Context, exec execer, cfg Options, file migrationFile, data []byte, elapsed time.Duration) error {
+	if file.repeatable {
+		return recordRepeatable(ctx, exec, cfg.TargetSchema, file, data, elapsed, cfg.AppliedBy, version.String())
+	}
+
+	table := qualifyIdent(cfg.TargetSchema, "tinytoe_migrations")
+	insert := fmt.Sprintf(`
+INSERT INTO %s (version, filename, checksum, execution_ms, applied_by, tinytoe_version, applied_order)
+VALUES ($1, $2, $3, $4, $5, $6, %s)`, table, nextAppliedOrder(table))
+	if _, err := exec.ExecContext(ctx, insert, file.version, file.filename, checksum(data), elapsed.Milliseconds(), cfg.AppliedBy, version.String()); err != nil {
+		return fmt.Errorf("record migration %s: %w", file.filename, err)
+	}
+	return nil
+}
+
+// nextAppliedOrder is the SQL expression numbering a new tinytoe_migrations
+// row after every row already recorded. Runs hold the migration lock, so the
+// numbers are unique and follow the actual order of application, which
+// differs from version order once migrations are applied out of order.
+func nextAppliedOrder(table string) string {
+	return fmt.Sprintf("(SELECT COALESCE(MAX(applied_order), 0) + 1 FROM %s)", table)
+}
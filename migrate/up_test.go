@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionSettingsRoundsTimeoutsUp(t *testing.T) {
+	half := 500 * time.Microsecond
+	zero := time.Duration(0)
+	tests := []struct {
+		name       string
+		cfg        Options
+		directives migrationDirectives
+		want       []string
+	}{
+		{
+			name: "unset",
+			cfg:  Options{TargetSchema: "app"},
+			want: []string{`search_path = "app"`},
+		},
+		{
+			name: "sub-millisecond configuration",
+			cfg:  Options{TargetSchema: "app", LockTimeout: half, StatementTimeout: 1500 * time.Microsecond},
+			want: []string{`search_path = "app"`, "lock_timeout = 1", "statement_timeout = 2"},
+		},
+		{
+			name:       "directives take precedence",
+			cfg:        Options{TargetSchema: "app", LockTimeout: time.Second, StatementTimeout: time.Minute},
+			directives: migrationDirectives{lockTimeout: &half, statementTimeout: &zero},
+			want:       []string{`search_path = "app"`, "lock_timeout = 1", "statement_timeout = 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionSettings(tt.cfg, tt.directives); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("sessionSettings() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// verifySchemaPrefix starts the name of every schema Verify creates, so
+// leftovers from a killed run are easy to spot.
+const verifySchemaPrefix = "tinytoe_verify_"
+
+// VerifyResult describes the outcome of Verify.
+type VerifyResult struct {
+	// Schema is the temporary schema the migrations were replayed in. It has
+	// been dropped by the time Verify returns.
+	Schema string
+	// Applied lists the migrations that replayed successfully.
+	Applied []Migration
+	// Failed is the migration that failed to apply, if any.
+	Failed *Migration
+}
+
+// Verify replays every migration from scratch in a uniquely named temporary
+// schema, then drops that schema whether or not the replay succeeded. The
+// target schema is left untouched. Migrations that name a schema explicitly
+// or create database-wide objects such as extensions reach beyond the
+// temporary schema.
+func (m *Migrator) Verify(ctx context.Context) (result *VerifyResult, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if _, err := discoverMigrations(m.opts.FS, m.opts.DumpFile); err != nil {
+		return nil, err
+	}
+	if err := m.ping(ctx); err != nil {
+		return nil, err
+	}
+
+	scratch := m.scratchMigrator(verifySchemaPrefix)
+
+	result = &VerifyResult{Schema: scratch.opts.TargetSchema, Applied: []Migration{}}
+	defer func() {
+		// Drop even when the run was cancelled.
+		dropErr := dropTargetSchema(context.WithoutCancel(ctx), m.db, scratch.opts.TargetSchema)
+		if dropErr != nil && err == nil {
+			err = fmt.Errorf("clean up verification schema: %w", dropErr)
+		}
+	}()
+
+	if err := scratch.Init(ctx); err != nil {
+		return result, err
+	}
+	up, err := scratch.Up(ctx, UpOptions{})
+	if up != nil {
+		result.Applied = up.Applied
+		result.Failed = up.Failed
+	}
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// scratchMigrator returns a Migrator sharing m's connection and migrations
+// but targeting a new, uniquely named schema that starts with prefix. The
+// caller creates and drops the schema. A migration that fails is replayed
+// statement by statement to name the failing statement.
+func (m *Migrator) scratchMigrator(prefix string) *Migrator {
+	opts := m.opts
+	opts.TargetSchema = fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+	opts.Protected = false
+	opts.Hooks.Initialized = nil
+	return &Migrator{db: m.db, opts: opts, eachStatement: true}
+}